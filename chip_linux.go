@@ -0,0 +1,94 @@
+package gpio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/mkch/gpio/internal/sys"
+	"golang.org/x/sys/unix"
+)
+
+// DeviceRoot is the directory in which ChipDevices, OpenChip and FindLine
+// look for GPIO chip devices. It should only be changed before calling any
+// of them, for example to a temporary directory in tests.
+var DeviceRoot = "/dev"
+
+// chipDevicePattern is the file name pattern of GPIO chip devices in DeviceRoot.
+const chipDevicePattern = "gpiochip*"
+
+// ChipPath returns the path of a GPIO chip device. Parameter chip can be:
+//
+//   - a path containing a "/", such as a udev symlink "/dev/gpio/by-name/header".
+//   - the number of the chip, such as "0" for "gpiochip0".
+//   - the name of a device in DeviceRoot, such as "gpiochip0".
+//   - the label of the chip, such as "pinctrl-bcm2835".
+//
+// The returned error wraps unix.ENOENT if the chip is not found.
+func ChipPath(chip string) (path string, err error) {
+	return chipPath(chip, chipLabel)
+}
+
+// chipPath is ChipPath with a function returning the label of a chip device.
+func chipPath(chip string, label func(path string) (string, error)) (path string, err error) {
+	if chip == "" {
+		err = fmt.Errorf("lookup chip failed: empty chip: %w", unix.ENOENT)
+		return
+	}
+	if strings.Contains(chip, "/") {
+		return chip, nil
+	}
+	if _, err := strconv.ParseUint(chip, 10, 32); err == nil {
+		return filepath.Join(DeviceRoot, "gpiochip"+chip), nil
+	}
+	path = filepath.Join(DeviceRoot, chip)
+	if _, err = os.Stat(path); err == nil {
+		return
+	} else if !os.IsNotExist(err) {
+		err = fmt.Errorf("lookup chip %q failed: %w", chip, err)
+		return
+	}
+	// Chips whose label can't be read are skipped, and the first error is
+	// reported if no chip matches.
+	var firstErr error
+	for _, dev := range ChipDevices() {
+		var devPath = filepath.Join(DeviceRoot, dev)
+		devLabel, err := label(devPath)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if devLabel == chip {
+			return devPath, nil
+		}
+	}
+	if firstErr != nil {
+		err = fmt.Errorf("lookup chip %q failed: %w", chip, firstErr)
+	} else {
+		err = fmt.Errorf("lookup chip %q failed: no such chip: %w", chip, unix.ENOENT)
+	}
+	return
+}
+
+// chipLabel returns the label of the chip device at path.
+func chipLabel(path string) (label string, err error) {
+	fd, err := unix.Open(path, unix.O_RDONLY, 0)
+	if err != nil {
+		err = fmt.Errorf("open chip %v failed: %w", path, err)
+		return
+	}
+	defer unix.Close(fd)
+	var arg sys.GPIOChipInfo
+	err = sys.Ioctl(fd, sys.GPIO_GET_CHIPINFO_IOCTL, uintptr(unsafe.Pointer(&arg)))
+	if err != nil {
+		err = fmt.Errorf("get GPIO chip info of %s failed: %w", path, err)
+		return
+	}
+	label = sys.Str32(arg.Label)
+	return
+}
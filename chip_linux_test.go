@@ -0,0 +1,79 @@
+package gpio
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/mkch/asserting"
+	"golang.org/x/sys/unix"
+)
+
+func TestChipPath(t1 *testing.T) {
+	t := NewTB(t1)
+	root, err := ioutil.TempDir("", "gpio-dev")
+	t.AssertNoError(err)
+	defer os.RemoveAll(root)
+	defer func(oldRoot string) { DeviceRoot = oldRoot }(DeviceRoot)
+	DeviceRoot = root
+
+	for _, dev := range []string{"gpiochip0", "gpiochip1", "gpiochip10"} {
+		t.AssertNoError(ioutil.WriteFile(filepath.Join(root, dev), nil, 0666))
+	}
+	t.AssertEqualSlice(ChipDevices(), []string{"gpiochip0", "gpiochip1", "gpiochip10"})
+
+	var labels = map[string]string{
+		filepath.Join(root, "gpiochip0"):  "pinctrl-bcm2835",
+		filepath.Join(root, "gpiochip1"):  "raspberrypi-exp-gpio",
+		filepath.Join(root, "gpiochip10"): "gpio-mockup",
+	}
+	label := func(path string) (string, error) {
+		return labels[path], nil
+	}
+
+	path, err := chipPath("gpiochip1", label)
+	t.AssertNoError(err)
+	t.AssertEqual(path, filepath.Join(root, "gpiochip1"))
+
+	path, err = chipPath("10", label)
+	t.AssertNoError(err)
+	t.AssertEqual(path, filepath.Join(root, "gpiochip10"))
+
+	path, err = chipPath("pinctrl-bcm2835", label)
+	t.AssertNoError(err)
+	t.AssertEqual(path, filepath.Join(root, "gpiochip0"))
+
+	path, err = chipPath("/dev/gpio/by-name/header", label)
+	t.AssertNoError(err)
+	t.AssertEqual(path, "/dev/gpio/by-name/header")
+
+	_, err = chipPath("no-such-chip", label)
+	t.AssertTrue(errors.Is(err, os.ErrNotExist))
+	_, err = chipPath("", label)
+	t.AssertTrue(errors.Is(err, os.ErrNotExist))
+
+	_, err = chipPath("pinctrl-bcm2835", func(path string) (string, error) {
+		return "", errors.New("permission denied")
+	})
+	t.Assert(err, NotEquals(nil))
+
+	// Unreadable chips are skipped.
+	denied := func(path string) (string, error) {
+		if path == filepath.Join(root, "gpiochip0") {
+			return "", os.ErrPermission
+		}
+		return labels[path], nil
+	}
+	path, err = chipPath("gpio-mockup", denied)
+	t.AssertNoError(err)
+	t.AssertEqual(path, filepath.Join(root, "gpiochip10"))
+	_, err = chipPath("no-such-chip", denied)
+	t.AssertTrue(errors.Is(err, os.ErrPermission))
+
+	// Stat errors other than not-exist.
+	DeviceRoot = filepath.Join(root, "gpiochip0") // Not a directory.
+	_, err = chipPath("gpio-mockup", label)
+	t.AssertTrue(errors.Is(err, unix.ENOTDIR))
+}
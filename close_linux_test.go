@@ -0,0 +1,108 @@
+package gpio
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/mkch/asserting"
+	"github.com/mkch/gpio/internal/fdevents"
+	"golang.org/x/sys/unix"
+)
+
+// closeConcurrently calls close in n goroutines, while calling use in n other
+// goroutines, and returns the number of successful close calls.
+func closeConcurrently(n int, close func() error, use func()) (succeeded int32) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				use()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if close() == nil {
+				atomic.AddInt32(&succeeded, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	return
+}
+
+func TestLinesClose(t1 *testing.T) {
+	t := NewTB(t1)
+	var pipe [2]int
+	t.AssertNoError(unix.Pipe(pipe[:]))
+	defer unix.Close(pipe[1])
+
+	// Not a GPIO line, but good enough to close.
+	var lines = &Lines{fd: pipe[0], numLines: 2}
+	t.AssertEqual(closeConcurrently(8, lines.Close, func() {
+		lines.SetBits(1)
+		lines.Bits()
+	}), int32(1))
+
+	t.AssertTrue(errors.Is(lines.Close(), ErrClosed))
+	_, err := lines.Values()
+	t.AssertTrue(errors.Is(err, ErrClosed))
+	t.AssertTrue(errors.Is(lines.SetValues([]byte{1, 0}), ErrClosed))
+	t.AssertTrue(errors.Is(lines.SetBits(0), ErrClosed))
+	_, err = lines.ValuesMasked(1)
+	t.AssertTrue(errors.Is(err, ErrClosed))
+	t.AssertTrue(errors.Is(lines.Reconfigure(Input, nil), ErrClosed))
+}
+
+func TestChipClose(t1 *testing.T) {
+	t := NewTB(t1)
+	// Not a GPIO chip, but good enough to close.
+	chip, err := OpenChip("/dev/null")
+	t.AssertNoError(err)
+
+	t.AssertEqual(closeConcurrently(8, chip.Close, func() {
+		chip.Info()
+		chip.LineInfo(0)
+	}), int32(1))
+
+	t.AssertTrue(errors.Is(chip.Close(), ErrClosed))
+	_, err = chip.Info()
+	t.AssertTrue(errors.Is(err, ErrClosed))
+	_, err = chip.LineInfo(0)
+	t.AssertTrue(errors.Is(err, ErrClosed))
+	_, err = chip.OpenLines([]uint32{0}, nil, Input, "")
+	t.AssertTrue(errors.Is(err, ErrClosed))
+	_, err = chip.OpenLineWithEvents(0, Input, BothEdges, "")
+	t.AssertTrue(errors.Is(err, ErrClosed))
+	_, err = chip.WatchLineInfo(0)
+	t.AssertTrue(errors.Is(err, ErrClosed))
+	t.AssertTrue(errors.Is(chip.UnwatchLineInfo(0), ErrClosed))
+	_, ok := <-chip.LineInfoChanges()
+	t.AssertTrue(!ok)
+}
+
+func TestLineWithEventClose(t1 *testing.T) {
+	t := NewTB(t1)
+	var pipe [2]int
+	t.AssertNoError(unix.Pipe(pipe[:]))
+	defer unix.Close(pipe[1])
+
+	events, err := fdevents.New(pipe[0], false /*NOT close fd*/, unix.EPOLLIN, func(fd int) (*fdevents.Event, error) {
+		return nil, nil
+	})
+	t.AssertNoError(err)
+	// Not a GPIO line, but good enough to close.
+	var line = &LineWithEvent{l: &Line{fd: pipe[0], numLines: 1}, events: events}
+	t.AssertEqual(closeConcurrently(8, line.Close, func() {
+		line.Value()
+	}), int32(1))
+
+	t.AssertTrue(errors.Is(line.Close(), ErrClosed))
+	_, err = line.Value()
+	t.AssertTrue(errors.Is(err, ErrClosed))
+	_, ok := <-line.Events()
+	t.AssertTrue(!ok)
+}
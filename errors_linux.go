@@ -0,0 +1,115 @@
+package gpio
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// The reasons of common failures of GPIO operations.
+// Test them with errors.Is, because the returned errors are *Error or
+// wrap *Error.
+var (
+	// ErrLineBusy means the line is already requested by another consumer,
+	// in this or another process. See Error.Consumer.
+	ErrLineBusy = errors.New("GPIO line is busy")
+	// ErrPermission means the access to the chip or line is denied.
+	// Check the owner and mode of the chip device, usually set by udev rules.
+	ErrPermission = errors.New("permission denied")
+	// ErrNoSuchChip means the chip does not exist.
+	ErrNoSuchChip = errors.New("no such GPIO chip")
+	// ErrInvalidOffset means a line offset is out of the range of the chip.
+	ErrInvalidOffset = errors.New("invalid GPIO line offset")
+	// ErrUnsupported means the operation or option is not supported by the
+	// kernel or the chip driver.
+	ErrUnsupported = errors.New("not supported")
+)
+
+// Error is the error returned when an operation on a GPIO chip or lines fails.
+// Use errors.Is to test the reason, such as ErrLineBusy and ErrPermission,
+// and errors.As to get the details.
+// The underlying syscall.Errno, if any, can be tested with errors.Is too.
+type Error struct {
+	Op      string   // The failed operation, such as "open GPIO lines".
+	Chip    string   // The chip device.
+	Offsets []uint32 // The offsets of the lines operated on, if any.
+	// The consumer currently holding the line, only set with ErrLineBusy if known.
+	Consumer string
+	Err      error // The underlying error.
+	// The reason, one of the ErrXxx above, or nil if unknown.
+	reason error
+}
+
+func (e *Error) Error() string {
+	var s = e.Op
+	if len(e.Offsets) > 0 {
+		s += fmt.Sprintf(" %v on", e.Offsets)
+	}
+	s += fmt.Sprintf(" %v failed: %v", e.Chip, e.Err)
+	if e.Consumer != "" {
+		s += fmt.Sprintf(", used by %q", e.Consumer)
+	}
+	return s
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the reason of e.
+func (e *Error) Is(target error) bool {
+	return e.reason != nil && target == e.reason
+}
+
+// errnoReason returns the reason of err according to the errno wrapped by err,
+// or nil if unknown.
+func errnoReason(err error) error {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return nil
+	}
+	switch errno {
+	case unix.EBUSY:
+		return ErrLineBusy
+	case unix.EACCES, unix.EPERM:
+		return ErrPermission
+	case unix.ENOENT, unix.ENODEV, unix.ENXIO:
+		return ErrNoSuchChip
+	case unix.ENOTTY, unix.EOPNOTSUPP:
+		return ErrUnsupported
+	}
+	return nil
+}
+
+// chipError returns an *Error of op on chip device.
+func chipError(op, chip string, err error) error {
+	return &Error{Op: op, Chip: chip, Err: err, reason: errnoReason(err)}
+}
+
+// lineError returns an *Error of op on the lines of c at offsets.
+// It queries c for the details if needed, such as the consumer of busy lines.
+func (c *Chip) lineError(op string, offsets []uint32, err error) error {
+	var e = &Error{Op: op, Chip: c.dev, Offsets: append([]uint32(nil), offsets...), Err: err, reason: errnoReason(err)}
+	switch {
+	case e.reason == ErrLineBusy:
+		for _, offset := range offsets {
+			if info, err := c.lineInfo(offset); err == nil && info.Consumer != "" {
+				e.Consumer = info.Consumer
+				break
+			}
+		}
+	case errors.Is(err, unix.EINVAL):
+		// EINVAL is also returned for invalid flags and values.
+		if info, err := c.Info(); err == nil {
+			for _, offset := range offsets {
+				if offset >= info.NumLines {
+					e.reason = ErrInvalidOffset
+					break
+				}
+			}
+		}
+	}
+	return e
+}
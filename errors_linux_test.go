@@ -0,0 +1,85 @@
+package gpio
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/mkch/asserting"
+	"golang.org/x/sys/unix"
+)
+
+func TestErrorReason(t1 *testing.T) {
+	t := NewTB(t1)
+	var reasons = map[unix.Errno]error{
+		unix.EBUSY:      ErrLineBusy,
+		unix.EACCES:     ErrPermission,
+		unix.EPERM:      ErrPermission,
+		unix.ENOENT:     ErrNoSuchChip,
+		unix.ENOTTY:     ErrUnsupported,
+		unix.EOPNOTSUPP: ErrUnsupported,
+		unix.EIO:        nil,
+	}
+	for errno, reason := range reasons {
+		err := chipError("open chip", "gpiochip0", errno)
+		t.AssertTrue(errors.Is(err, errno))
+		for _, r := range []error{ErrLineBusy, ErrPermission, ErrNoSuchChip, ErrInvalidOffset, ErrUnsupported} {
+			t.AssertEqual(errors.Is(err, r), r == reason)
+		}
+	}
+}
+
+func TestErrorMessage(t1 *testing.T) {
+	t := NewTB(t1)
+	var err error = &Error{Op: "open GPIO lines", Chip: "gpiochip0", Offsets: []uint32{3, 4}, Consumer: "relay", Err: unix.EBUSY, reason: ErrLineBusy}
+	t.AssertEqual(err.Error(), `open GPIO lines [3 4] on gpiochip0 failed: device or resource busy, used by "relay"`)
+	var e *Error
+	t.AssertTrue(errors.As(err, &e))
+	t.AssertEqual(e.Consumer, "relay")
+	t.AssertEqualSlice(e.Offsets, []uint32{3, 4})
+
+	err = chipError("open chip", "gpiochip9", unix.EACCES)
+	t.AssertEqual(err.Error(), "open chip gpiochip9 failed: permission denied")
+}
+
+func TestOpenChipError(t1 *testing.T) {
+	t := NewTB(t1)
+	root, err := ioutil.TempDir("", "gpio-dev")
+	t.AssertNoError(err)
+	defer os.RemoveAll(root)
+	defer func(oldRoot string) { DeviceRoot = oldRoot }(DeviceRoot)
+	DeviceRoot = root
+
+	_, err = OpenChip("gpiochip0")
+	t.AssertTrue(errors.Is(err, ErrNoSuchChip))
+	_, err = OpenChip(filepath.Join(root, "gpiochip0"))
+	t.AssertTrue(errors.Is(err, ErrNoSuchChip))
+	var e *Error
+	t.AssertTrue(errors.As(err, &e))
+	t.AssertEqual(e.Chip, filepath.Join(root, "gpiochip0"))
+}
+
+func TestUnsupportedError(t1 *testing.T) {
+	t := NewTB(t1)
+	// Not a GPIO chip, so it is treated as uAPI v1 and every ioctl fails with ENOTTY.
+	chip, err := OpenChip("/dev/null")
+	t.AssertNoError(err)
+	defer chip.Close()
+
+	_, err = chip.OpenLines([]uint32{1, 2}, nil, Input, "", WithDebounce(time.Millisecond))
+	t.AssertTrue(errors.Is(err, ErrUnsupported))
+	var e *Error
+	t.AssertTrue(errors.As(err, &e))
+	t.AssertEqual(e.Chip, "/dev/null")
+	t.AssertEqualSlice(e.Offsets, []uint32{1, 2})
+
+	_, err = chip.OpenLinesWithEvents([]uint32{1, 2}, Input, BothEdges, "")
+	t.AssertTrue(errors.Is(err, ErrUnsupported))
+
+	_, err = chip.LineInfo(1)
+	t.AssertTrue(errors.Is(err, ErrUnsupported))
+	t.AssertTrue(errors.Is(err, unix.ENOTTY))
+}
@@ -1,6 +1,7 @@
 package gpio
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"syscall"
@@ -14,10 +15,14 @@ import (
 
 // LineWithEvent is an opened GPIO line whose events can be subscribed.
 type LineWithEvent struct {
-	l      Line
+	l      *Line
 	events *fdevents.FdEvents
+	clock  EventClock // The event clock, always EventClockMonotonic for uAPI v1.
 }
 
+// Close releases the GPIO line and closes the channel returned by Events.
+// It is safe to call Close concurrently with the other methods.
+// Close returns ErrClosed if l is already closed, and so do the other methods.
 func (l *LineWithEvent) Close() (err error) {
 	// Close l.events first because Line.Close will close the fd,
 	// but l.events still needs it until it is Closed.
@@ -36,53 +41,381 @@ func (l *LineWithEvent) Value() (value byte, err error) {
 	return l.l.Value()
 }
 
-func readGPIOLineEventFd(fd int) *fdevents.Event {
+// Config returns the configuration of the GPIO line. See Lines.Config.
+func (l *LineWithEvent) Config() LineConfig {
+	return l.l.Config()
+}
+
+func readGPIOLineEventFd(fd int) (*fdevents.Event, error) {
 	var eventData sys.GPIOEventData
 	_, err := io.ReadFull(sys.FdReader(fd), (*[unsafe.Sizeof(eventData)]byte)(unsafe.Pointer(&eventData))[:])
 	if err != nil {
 		if err == syscall.EINTR {
-			return nil // ignore
+			return nil, nil // ignore
+		}
+		return nil, fmt.Errorf("failed to read GPIO event: %w", err)
+	}
+	t, err := eventTimeV1(eventData.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	return &fdevents.Event{
+		RisingEdge: eventData.ID == sys.GPIOEVENT_EVENT_RISING_EDGE,
+		Time:       t,
+		Timestamp:  time.Duration(eventData.Timestamp),
+	}, nil
+}
+
+// readGPIOV2LineEventFd is the uAPI v2 version of readGPIOLineEventFd.
+// The events are timestamped with clock.
+func readGPIOV2LineEventFd(fd int, clock EventClock) (*fdevents.Event, error) {
+	var eventData sys.GPIOV2LineEvent
+	_, err := io.ReadFull(sys.FdReader(fd), (*[unsafe.Sizeof(eventData)]byte)(unsafe.Pointer(&eventData))[:])
+	if err != nil {
+		if err == syscall.EINTR {
+			return nil, nil // ignore
 		}
-		panic(fmt.Errorf("failed to read GPIO event: %w", err))
+		return nil, fmt.Errorf("failed to read GPIO event: %w", err)
 	}
+	t, err := clock.time(eventData.TimestampNs)
+	if err != nil {
+		return nil, err
+	}
+	return &fdevents.Event{
+		RisingEdge: eventData.ID == sys.GPIO_V2_LINE_EVENT_RISING_EDGE,
+		Time:       t,
+		Timestamp:  time.Duration(eventData.TimestampNs),
+		Seqno:      eventData.Seqno,
+		LineSeqno:  eventData.LineSeqno,
+		Offset:     eventData.Offset,
+	}, nil
+}
+
+// monotonicTime converts a CLOCK_MONOTONIC timestamp in nanoseconds to wall clock time.
+// The uAPI v2 event timestamps are read from CLOCK_MONOTONIC by default.
+func monotonicTime(ns uint64) (t time.Time, err error) {
+	var now unix.Timespec
+	err = unix.ClockGettime(unix.CLOCK_MONOTONIC, &now)
+	if err != nil {
+		err = fmt.Errorf("failed to call clock_gettime: %w", err)
+		return
+	}
+	t = time.Now().Add(time.Duration(ns) - time.Duration(now.Nano()))
+	return
+}
+
+// eventTimeV1 converts the timestamp of a uAPI v1 event to wall clock time.
+// The uAPI v1 event timestamps are read from CLOCK_REALTIME before Linux 5.7,
+// and from CLOCK_MONOTONIC since then. The clock is detected by comparing
+// ns with the current time of both clocks.
+func eventTimeV1(ns uint64) (t time.Time, err error) {
+	var now unix.Timespec
+	err = unix.ClockGettime(unix.CLOCK_MONOTONIC, &now)
+	if err != nil {
+		err = fmt.Errorf("failed to call clock_gettime: %w", err)
+		return
+	}
+	if isRealtime(ns, uint64(now.Nano()), uint64(time.Now().UnixNano())) {
+		return time.Unix(0, int64(ns)), nil
+	}
+	return monotonicTime(ns)
+}
+
+// isRealtime returns whether timestamp ns is closer to realtimeNow than monotonicNow.
+func isRealtime(ns, monotonicNow, realtimeNow uint64) bool {
+	return absDiff(ns, realtimeNow) < absDiff(ns, monotonicNow)
+}
 
-	sec := uint64(time.Nanosecond) * eventData.Timestamp / uint64(time.Second)
-	nano := uint64(time.Nanosecond) * eventData.Timestamp % uint64(time.Second)
-	return &fdevents.Event{RisingEdge: eventData.ID == sys.GPIOEVENT_EVENT_RISING_EDGE, Time: time.Unix(int64(sec), int64(nano))}
+func absDiff(a, b uint64) uint64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
 }
 
-func newInputLineWithEvents(chipFd int, offset uint32, flags, eventFlags uint32, consumer string) (line *LineWithEvent, err error) {
+// newInputLineWithEvents requests an input line with GPIO events with uAPI v1.
+func (c *Chip) newInputLineWithEvents(offset uint32, flags, eventFlags uint32, consumer string, opts *lineOptions) (line *LineWithEvent, err error) {
 	var req = sys.GPIOEventRequest{
 		LineOffset:  offset,
 		HandleFlags: uint32(flags),
 		EventFlags:  uint32(eventFlags)}
 	copy(req.ConsumerLabel[:], consumer)
-	err = sys.Ioctl(chipFd, sys.GPIO_GET_LINEEVENT_IOCTL, uintptr(unsafe.Pointer(&req)))
+	err = c.ioctl(sys.GPIO_GET_LINEEVENT_IOCTL, unsafe.Pointer(&req))
 	if err != nil {
-		err = fmt.Errorf("request GPIO event failed: ioctl %w", err)
+		err = c.lineError("request GPIO event", []uint32{offset}, err)
 		return
 	}
-	events, err := fdevents.New(int(req.Fd), false /*NOT close fd*/, unix.EPOLLIN|unix.EPOLLPRI, readGPIOLineEventFd)
+	// The uAPI v1 event data does not carry the offset.
+	readFd := func(fd int) (*fdevents.Event, error) {
+		event, err := readGPIOLineEventFd(fd)
+		if event != nil {
+			event.Offset = offset
+		}
+		return event, err
+	}
+	events, err := fdevents.NewBuffered(int(req.Fd), false /*NOT close fd*/, unix.EPOLLIN|unix.EPOLLPRI, readFd, opts.eventBufferSize, opts.overflowPolicy)
 	if err != nil {
-		unix.Close(int(req.Fd))
+		closeFd(c.sim, int(req.Fd))
 		return
 	}
 
 	line = &LineWithEvent{
-		l:      Line{fd: int(req.Fd), numLines: 1},
+		l:      &Line{fd: int(req.Fd), numLines: 1, offsets: []uint32{offset}, sim: c.sim},
 		events: events,
 	}
 	return
 }
 
+// newInputLinesWithEventsV2 requests input lines with GPIO events with uAPI v2.
+// Config is the configuration of the requested lines.
+func (c *Chip) newInputLinesWithEventsV2(offsets []uint32, config *sys.GPIOV2LineConfig, consumer string, opts *lineOptions) (lines *LinesWithEvents, err error) {
+	l, err := c.requestLinesV2(offsets, config, consumer)
+	if err != nil {
+		return
+	}
+	clock := opts.eventClock
+	readFd := func(fd int) (*fdevents.Event, error) {
+		return readGPIOV2LineEventFd(fd, clock)
+	}
+	events, err := fdevents.NewBuffered(l.fd, false /*NOT close fd*/, unix.EPOLLIN|unix.EPOLLPRI, readFd, opts.eventBufferSize, opts.overflowPolicy)
+	if err != nil {
+		l.Close()
+		return
+	}
+
+	lines = &LinesWithEvents{
+		l:      l,
+		events: events,
+		clock:  clock,
+	}
+	return
+}
+
 type Event = fdevents.Event
 
+// OverflowPolicy decides what to do with a new event when the event channel is full.
+// See WithEventBuffer.
+type OverflowPolicy = fdevents.OverflowPolicy
+
+const (
+	// OverflowDropOldest discards the oldest unread event in the channel to make room.
+	// With a channel buffer of 1, only the latest event is kept.
+	OverflowDropOldest = fdevents.OverflowDropOldest
+	// OverflowDropNewest discards the new event.
+	OverflowDropNewest = fdevents.OverflowDropNewest
+	// OverflowBlock waits until there is room in the channel. Further events are
+	// buffered in the kernel meanwhile, and may get lost there.
+	OverflowBlock = fdevents.OverflowBlock
+	// OverflowError closes the channel, and Err returns ErrOverflow.
+	OverflowError = fdevents.OverflowError
+)
+
+// EventClock is the clock used to timestamp GPIO events. See WithEventClock.
+type EventClock int
+
+const (
+	// EventClockMonotonic is CLOCK_MONOTONIC, the default.
+	EventClockMonotonic EventClock = iota
+	// EventClockRealtime is CLOCK_REALTIME. Requires Linux 5.11+.
+	EventClockRealtime
+	// EventClockHTE is the hardware timestamp engine(HTE) of the chip,
+	// if it has one. Requires Linux 6.1+.
+	// Event.Time is converted as if the HTE clock were CLOCK_MONOTONIC.
+	EventClockHTE
+)
+
+func (clock EventClock) String() string {
+	switch clock {
+	case EventClockMonotonic:
+		return "monotonic"
+	case EventClockRealtime:
+		return "realtime"
+	case EventClockHTE:
+		return "hte"
+	default:
+		return fmt.Sprintf("EventClock(%d)", int(clock))
+	}
+}
+
+// time converts timestamp ns of clock to wall clock time.
+func (clock EventClock) time(ns uint64) (time.Time, error) {
+	if clock == EventClockRealtime {
+		return time.Unix(0, int64(ns)), nil
+	}
+	return monotonicTime(ns)
+}
+
+// ErrOverflow is the error reported if the event channel overflows with OverflowError policy.
+var ErrOverflow = fdevents.ErrOverflow
+
+// ErrClosed is the error returned by the methods of Chip, Lines, Line, LineWithEvent
+// and LinesWithEvents after Close, including Close itself.
+// It is also returned by WaitEdge and WaitValue if the event channel is closed by Close.
+// Test it with errors.Is, because it may be wrapped.
+var ErrClosed = fdevents.ErrClosed
+
 // Events returns a channel from which the occurrence time of GPIO events can be read.
 // The GPIO events of this line will be sent to the returned channel,
 // and the channel is closed when l is closed.
 //
-// Package gpio will not block sending to the channel: it only keeps the lastest
-// value in the channel.
+// Unless configured by WithEventBuffer, package gpio will not block sending to
+// the channel: it only keeps the lastest value in the channel.
 func (l *LineWithEvent) Events() <-chan *Event {
 	return l.events.Events()
 }
+
+// Overwritten returns the number of events discarded because the channel
+// returned by Events was full.
+func (l *LineWithEvent) Overwritten() uint64 {
+	return l.events.Overwritten()
+}
+
+// Err returns the error that caused the channel returned by Events to be closed,
+// such as ErrOverflow or an error reading the event. It returns nil if the channel
+// is open or closed by Close.
+func (l *LineWithEvent) Err() error {
+	return l.events.Err()
+}
+
+// WaitEdge waits for the next event of edge and returns it. Parameter edge is RisingEdge,
+// FallingEdge or BothEdges. Events already in the channel returned by Events are
+// taken into account, and the events not of edge are discarded.
+// If ctx is done first, WaitEdge returns ctx.Err().
+// If the channel is closed first, WaitEdge returns the error returned by Err,
+// or ErrClosed if l is closed.
+func (l *LineWithEvent) WaitEdge(ctx context.Context, edge EventFlag) (*Event, error) {
+	if edge&BothEdges == 0 || edge&^BothEdges != 0 {
+		return nil, fmt.Errorf("wait GPIO edge failed: invalid edge %v", edge)
+	}
+	return l.events.Wait(ctx, func(e *Event) bool {
+		if e.RisingEdge {
+			return edge&RisingEdge != 0
+		}
+		return edge&FallingEdge != 0
+	})
+}
+
+// WaitValue waits until the value of the line becomes value, 1 (high) or 0 (low),
+// and returns the event of the edge changing the value. If the line already has
+// the value, WaitValue returns immediately an Event made from reading the value:
+// RisingEdge is whether value is 1, Time is the time of reading, and the other
+// fields are zero except Offset.
+// The events occurred before reading the current value are discarded, because
+// the value is already the result of them. They are told by the timestamps,
+// except for EventClockHTE, whose clock can't be read, in which case the events
+// already in the channel returned by Events are discarded.
+// The edge must be requested when the line is opened, or WaitValue waits until
+// ctx is done. See WaitEdge for the errors returned.
+func (l *LineWithEvent) WaitValue(ctx context.Context, value byte) (*Event, error) {
+	if value != 0 {
+		value = 1
+	}
+	after, err := eventsAfterNow(l.events, l.clock, l.l.v2)
+	if err != nil {
+		return nil, fmt.Errorf("wait GPIO value failed: %w", err)
+	}
+	current, err := l.Value()
+	if err != nil {
+		return nil, err
+	}
+	if current == value {
+		return &Event{RisingEdge: value == 1, Time: time.Now(), Offset: l.l.offsets[0]}, nil
+	}
+	return l.events.Wait(ctx, func(e *Event) bool {
+		return e.RisingEdge == (value == 1) && after(e)
+	})
+}
+
+// eventsAfterNow returns a function reporting whether an event of events
+// occurred after calling eventsAfterNow, by comparing its timestamp with the
+// current time of clock. Parameter v2 is whether the events are of uAPI v2,
+// otherwise the clock is told from the timestamps, see eventTimeV1.
+// The clock of EventClockHTE can't be read, so the events already in the
+// channel are discarded, and all the others are reported as after.
+func eventsAfterNow(events *fdevents.FdEvents, clock EventClock, v2 bool) (after func(e *Event) bool, err error) {
+	if clock == EventClockHTE {
+		for drained := false; !drained; {
+			select {
+			case _, ok := <-events.Events():
+				drained = !ok
+			default:
+				drained = true
+			}
+		}
+		return func(e *Event) bool { return true }, nil
+	}
+	var monotonic, realtime unix.Timespec
+	if err = unix.ClockGettime(unix.CLOCK_MONOTONIC, &monotonic); err == nil {
+		err = unix.ClockGettime(unix.CLOCK_REALTIME, &realtime)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to call clock_gettime: %w", err)
+		return
+	}
+	var monotonicNow, realtimeNow = uint64(monotonic.Nano()), uint64(realtime.Nano())
+	return func(e *Event) bool {
+		ts := uint64(e.Timestamp)
+		if clock == EventClockRealtime || !v2 && isRealtime(ts, monotonicNow, realtimeNow) {
+			return ts >= realtimeNow
+		}
+		return ts >= monotonicNow
+	}, nil
+}
+
+// LinesWithEvents is a batch of opened GPIO lines whose events can be subscribed.
+// The events of all the lines are delivered through a single channel.
+type LinesWithEvents struct {
+	l      *Lines
+	events *fdevents.FdEvents
+	clock  EventClock // See LineWithEvent.
+}
+
+// Close releases the GPIO lines and closes the channel returned by Events.
+// See LineWithEvent.Close.
+func (l *LinesWithEvents) Close() (err error) {
+	// See LineWithEvent.Close for the order.
+	err1 := l.events.Close()
+	err2 := l.l.Close()
+	if err1 != nil {
+		return err1
+	}
+	return err2
+}
+
+// Offsets returns the offsets of the GPIO lines, in the order used to request them.
+func (l *LinesWithEvents) Offsets() []uint32 {
+	return l.l.Offsets()
+}
+
+// Config returns the configurations of the GPIO lines. See Lines.Config.
+func (l *LinesWithEvents) Config() []LineConfig {
+	return l.l.Config()
+}
+
+// Values returns the current values of the GPIO lines. 1 (high) or 0 (low).
+func (l *LinesWithEvents) Values() (values []byte, err error) {
+	return l.l.Values()
+}
+
+// Events returns a channel from which the GPIO events of all the lines can be read.
+// Event.Offset is the offset of the line on which the event occurred.
+// The channel is closed when l is closed.
+//
+// Unless configured by WithEventBuffer, package gpio will not block sending to
+// the channel: it only keeps the lastest value in the channel.
+func (l *LinesWithEvents) Events() <-chan *Event {
+	return l.events.Events()
+}
+
+// Overwritten returns the number of events discarded because the channel
+// returned by Events was full.
+func (l *LinesWithEvents) Overwritten() uint64 {
+	return l.events.Overwritten()
+}
+
+// Err returns the error that caused the channel returned by Events to be closed,
+// such as ErrOverflow or an error reading the event. It returns nil if the channel
+// is open or closed by Close.
+func (l *LinesWithEvents) Err() error {
+	return l.events.Err()
+}
@@ -0,0 +1,55 @@
+package gpio
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/mkch/asserting"
+	"golang.org/x/sys/unix"
+)
+
+func TestIsRealtime(t1 *testing.T) {
+	t := NewTB(t1)
+	const uptime = uint64(3 * time.Hour)
+	const now = uint64(1700000000 * time.Second)
+	t.AssertTrue(isRealtime(now-uint64(time.Millisecond), uptime, now))
+	t.AssertTrue(!isRealtime(uptime-uint64(time.Millisecond), uptime, now))
+}
+
+func TestEventClockTime(t1 *testing.T) {
+	t := NewTB(t1)
+	realtime := time.Unix(1700000000, 5)
+	got, err := EventClockRealtime.time(uint64(realtime.UnixNano()))
+	t.AssertNoError(err)
+	t.AssertTrue(got.Equal(realtime))
+
+	// A monotonic timestamp of now converts to about now.
+	start := time.Now()
+	got, err = EventClockMonotonic.time(uint64(monotonicNow()))
+	t.AssertNoError(err)
+	t.AssertTrue(!got.Before(start.Add(-time.Second)) && !got.After(time.Now().Add(time.Second)))
+	got, err = eventTimeV1(uint64(monotonicNow()))
+	t.AssertNoError(err)
+	t.AssertTrue(!got.Before(start.Add(-time.Second)) && !got.After(time.Now().Add(time.Second)))
+	got, err = eventTimeV1(uint64(time.Now().UnixNano()))
+	t.AssertNoError(err)
+	t.AssertTrue(!got.Before(start.Add(-time.Second)) && !got.After(time.Now().Add(time.Second)))
+}
+
+func monotonicNow() time.Duration {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		panic(err)
+	}
+	return time.Duration(ts.Nano())
+}
+
+func TestWaitEdgeInvalid(t1 *testing.T) {
+	t := NewTB(t1)
+	var l LineWithEvent
+	_, err := l.WaitEdge(context.Background(), 0)
+	t.Assert(err, NotEquals(nil))
+	_, err = l.WaitEdge(context.Background(), BothEdges<<1)
+	t.Assert(err, NotEquals(nil))
+}
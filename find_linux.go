@@ -0,0 +1,116 @@
+package gpio
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LineLocation is the location of a GPIO line.
+type LineLocation struct {
+	Chip   string // The chip device, which can be used to call OpenChip.
+	Offset uint32 // The offset of the line on Chip.
+}
+
+func (loc LineLocation) String() string {
+	return fmt.Sprintf("%v %v", loc.Chip, loc.Offset)
+}
+
+// LineNotFoundError is the error returned by FindLine if no line has the name.
+type LineNotFoundError struct {
+	Name string
+}
+
+func (e *LineNotFoundError) Error() string {
+	return fmt.Sprintf("GPIO line %q not found", e.Name)
+}
+
+// AmbiguousLineError is the error returned by FindLine if more than one line
+// has the name.
+type AmbiguousLineError struct {
+	Name  string
+	Lines []LineLocation // All the lines found.
+}
+
+func (e *AmbiguousLineError) Error() string {
+	var lines = make([]string, len(e.Lines))
+	for i, loc := range e.Lines {
+		lines[i] = loc.String()
+	}
+	return fmt.Sprintf("GPIO line %q is ambiguous: found %v", e.Name, strings.Join(lines, ", "))
+}
+
+// FindLine finds the GPIO line with name in all the chips returned by ChipDevices.
+// Line names are specified by the chip driver, or by "gpio-line-names" in the device tree.
+// If no line has the name, the returned error is a *LineNotFoundError, which
+// is always the case for an empty name. If more than one line has the name,
+// the returned error is a *AmbiguousLineError.
+// Chips that can't be read are skipped, and the first error is returned if
+// the name is not found in the others.
+func FindLine(name string) (chip string, offset uint32, err error) {
+	if name == "" {
+		err = &LineNotFoundError{Name: name}
+		return
+	}
+	loc, err := findLine(name, ChipDevices(), chipLineNames)
+	if err != nil {
+		return
+	}
+	return loc.Chip, loc.Offset, nil
+}
+
+// findLine finds the line with name in devices.
+// lineNames returns the names of all the lines of a device indexed by offset.
+func findLine(name string, devices []string, lineNames func(device string) ([]string, error)) (loc LineLocation, err error) {
+	var found []LineLocation
+	var firstErr error // The first error of lineNames.
+	for _, dev := range devices {
+		names, err := lineNames(dev)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		for offset, lineName := range names {
+			if lineName == name {
+				found = append(found, LineLocation{Chip: dev, Offset: uint32(offset)})
+			}
+		}
+	}
+	switch len(found) {
+	case 0:
+		if firstErr != nil {
+			err = fmt.Errorf("find GPIO line %q failed: %w", name, firstErr)
+		} else {
+			err = &LineNotFoundError{Name: name}
+		}
+	case 1:
+		loc = found[0]
+	default:
+		err = &AmbiguousLineError{Name: name, Lines: found}
+	}
+	return
+}
+
+// chipLineNames returns the names of all the lines of a chip device indexed by offset.
+func chipLineNames(device string) (names []string, err error) {
+	chip, err := OpenChip(device)
+	if err != nil {
+		return
+	}
+	defer chip.Close()
+	info, err := chip.Info()
+	if err != nil {
+		return
+	}
+	names = make([]string, info.NumLines)
+	for i := range names {
+		var lineInfo LineInfo
+		lineInfo, err = chip.LineInfo(uint32(i))
+		if err != nil {
+			return nil, err
+		}
+		names[i] = lineInfo.Name
+	}
+	return
+}
@@ -0,0 +1,57 @@
+package gpio
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/mkch/asserting"
+)
+
+func TestFindLine(t1 *testing.T) {
+	t := NewTB(t1)
+	var chips = map[string][]string{
+		"gpiochip0": {"", "LED", "BUTTON"},
+		"gpiochip1": {"RESET", "", "BUTTON", ""},
+	}
+	lineNames := func(dev string) ([]string, error) {
+		if names, ok := chips[dev]; ok {
+			return names, nil
+		}
+		return nil, errors.New("no such chip")
+	}
+	devices := []string{"gpiochip0", "gpiochip1"}
+
+	loc, err := findLine("LED", devices, lineNames)
+	t.AssertNoError(err)
+	t.AssertEqual(loc, LineLocation{Chip: "gpiochip0", Offset: 1})
+	loc, err = findLine("RESET", devices, lineNames)
+	t.AssertNoError(err)
+	t.AssertEqual(loc, LineLocation{Chip: "gpiochip1", Offset: 0})
+
+	_, err = findLine("MOTOR", devices, lineNames)
+	var notFound *LineNotFoundError
+	t.AssertTrue(errors.As(err, &notFound))
+	t.AssertEqual(notFound.Name, "MOTOR")
+
+	_, err = findLine("BUTTON", devices, lineNames)
+	var ambiguous *AmbiguousLineError
+	t.AssertTrue(errors.As(err, &ambiguous))
+	t.AssertEqualSlice(ambiguous.Lines, []LineLocation{{"gpiochip0", 2}, {"gpiochip1", 2}})
+	t.AssertEqual(err.Error(), `GPIO line "BUTTON" is ambiguous: found gpiochip0 2, gpiochip1 2`)
+
+	_, err = findLine("LED", []string{"gpiochip9"}, lineNames)
+	t.Assert(err, NotEquals(nil))
+	t.AssertTrue(!errors.As(err, &notFound))
+
+	// Unreadable chips are skipped.
+	loc, err = findLine("RESET", []string{"gpiochip9", "gpiochip1"}, lineNames)
+	t.AssertNoError(err)
+	t.AssertEqual(loc, LineLocation{Chip: "gpiochip1", Offset: 0})
+	_, err = findLine("MOTOR", []string{"gpiochip0", "gpiochip9"}, lineNames)
+	t.AssertTrue(!errors.As(err, &notFound))
+	t.AssertEqual(err.Error(), `find GPIO line "MOTOR" failed: no such chip`)
+
+	_, _, err = FindLine("")
+	t.AssertTrue(errors.As(err, &notFound))
+	t.AssertEqual(notFound.Name, "")
+}
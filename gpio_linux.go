@@ -1,18 +1,23 @@
 package gpio
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
+	"sync"
+	"time"
 	"unsafe"
 
+	"github.com/mkch/gpio/internal/fdevents"
+	"github.com/mkch/gpio/internal/sim"
 	"github.com/mkch/gpio/internal/sys"
 	"golang.org/x/sys/unix"
 )
 
-// ChipDevices returns all available GPIO chip devices.
-// The returned paths can be used to call OpenChip.
+// ChipDevices returns the names of all available GPIO chip devices in DeviceRoot.
+// The returned names can be used to call OpenChip.
 func ChipDevices() (chips []string) {
-	chips, err := filepath.Glob("/dev/gpiochip*")
+	chips, err := filepath.Glob(filepath.Join(DeviceRoot, chipDevicePattern))
 	if err != nil {
 		// The only possible returned error is ErrBadPattern, when pattern is malformed.
 		panic(err)
@@ -34,35 +39,165 @@ type ChipInfo struct {
 }
 
 // Chip is certain GPIO chip.
+// It is safe to call the methods of Chip concurrently, including Close.
 type Chip struct {
 	dev string
-	fd  int
+	// Guards fd against being closed while in use.
+	lock sync.RWMutex
+	fd   int // -1 if closed.
+	// v1 is true if the kernel does not support uAPI v2(Linux 5.10+).
+	v1 bool
+	// The simulated device, nil if c is a real chip.
+	sim sim.Device
+
+	infoWatcherLock sync.Mutex
+	// The epoll_wait loop reading line info changes. Started by the first WatchLineInfo.
+	infoWatcher *fdevents.Watcher
+	infoChanges chan *LineInfoChange
+	// Whether infoChanges is closed.
+	infoClosed bool
+	// The error that caused infoChanges to be closed.
+	// Not guarded by infoWatcherLock, which is held when waiting for the loop to exit.
+	infoErrLock sync.Mutex
+	infoErr     error
 }
 
 // OpenChip opens a certain GPIO chip device.
+// Parameter device can be a path, a number, a device name or a label of the chip.
+// See ChipPath for details. The simulated chips of package gpiotest are not
+// chip devices, and are opened by gpiotest.Chip.Open instead.
 func OpenChip(device string) (chip *Chip, err error) {
-	devPath := filepath.Join("/dev", device)
+	devPath, err := ChipPath(device)
+	if err != nil {
+		err = chipError("open chip", device, err)
+		return
+	}
 	fd, err := unix.Open(devPath, unix.O_RDONLY, 0)
 	if err != nil {
-		err = fmt.Errorf("open chip %v failed: %w", devPath, err)
+		err = chipError("open chip", devPath, err)
 		return
 	}
-	chip = &Chip{dev: device, fd: fd}
+	chip = newChip(device, fd, nil)
 	return
 }
 
+func init() {
+	sim.OpenChip = func(name string, dev sim.Device) (interface{}, error) {
+		chip, err := openSimChip(name, dev)
+		if err != nil {
+			return nil, err
+		}
+		return chip, nil
+	}
+}
+
+// openSimChip opens the simulated chip device dev with name.
+func openSimChip(name string, dev sim.Device) (*Chip, error) {
+	fd, err := dev.Open()
+	if err != nil {
+		return nil, chipError("open chip", name, err)
+	}
+	return newChip(name, fd, dev), nil
+}
+
+// newChip returns a Chip of the opened chip fd of device.
+// SimDev is the simulated device, nil for a real one.
+func newChip(device string, fd int, simDev sim.Device) *Chip {
+	chip := &Chip{
+		dev:         device,
+		fd:          fd,
+		sim:         simDev,
+		infoChanges: make(chan *LineInfoChange, lineInfoChangesBufferSize),
+	}
+	chip.v1 = !chip.supportsV2()
+	return chip
+}
+
+// supportsV2 returns whether the GPIO character device uAPI v2 is supported
+// on the chip.
+func (c *Chip) supportsV2() bool {
+	var arg sys.GPIOV2LineInfo
+	err := c.ioctl(sys.GPIO_V2_GET_LINEINFO_IOCTL, unsafe.Pointer(&arg))
+	// Unknown ioctl.
+	return err != unix.ENOTTY
+}
+
+// Close closes the chip. The lines opened on the chip are not affected.
+// Close returns ErrClosed if c is already closed, and so do the other methods.
 func (c *Chip) Close() (err error) {
-	err = unix.Close(c.fd)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.fd < 0 {
+		return ErrClosed
+	}
+	// Stop reading line info changes before closing the fd.
+	c.infoWatcherLock.Lock()
+	if !c.infoClosed {
+		if c.infoWatcher != nil {
+			err = c.infoWatcher.Close()
+		} else {
+			close(c.infoChanges)
+		}
+		c.infoClosed = true
+	}
+	c.infoWatcherLock.Unlock()
+	if err != nil {
+		return
+	}
+	err = closeFd(c.sim, c.fd)
 	c.fd = -1
 	return
 }
 
+// ioctl calls ioctl on the fd of c, or returns ErrClosed if c is closed.
+func (c *Chip) ioctl(request uintptr, arg unsafe.Pointer) error {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.fd < 0 {
+		return ErrClosed
+	}
+	return ioctl(c.sim, c.fd, request, arg)
+}
+
+// ioctl calls ioctl on fd, which is handled by simDev if it is not nil.
+func ioctl(simDev sim.Device, fd int, request uintptr, arg unsafe.Pointer) error {
+	if simDev != nil {
+		return simIoctl(simDev, fd, request, arg)
+	}
+	return sys.Ioctl(fd, request, uintptr(arg))
+}
+
+// simIoctl calls simDev.Ioctl with a copy of the argument, so arg does not escape
+// to heap and allocation-free methods stay allocation-free on real chips.
+func simIoctl(simDev sim.Device, fd int, request uintptr, arg unsafe.Pointer) error {
+	// The size of the argument is encoded in the request, see _IOC_SIZE.
+	const maxSize = 1<<14 - 1
+	size := request >> 16 & maxSize
+	if size == 0 {
+		return simDev.Ioctl(fd, request, nil)
+	}
+	argBytes := (*[maxSize]byte)(arg)[:size:size]
+	var buf = make([]byte, size)
+	copy(buf, argBytes)
+	err := simDev.Ioctl(fd, request, unsafe.Pointer(&buf[0]))
+	copy(argBytes, buf)
+	return err
+}
+
+// closeFd closes fd, which is handled by simDev if it is not nil.
+func closeFd(simDev sim.Device, fd int) error {
+	if simDev != nil {
+		return simDev.Close(fd)
+	}
+	return unix.Close(fd)
+}
+
 // Info returns the information of this GPIO chip.
 func (c *Chip) Info() (info ChipInfo, err error) {
 	var arg sys.GPIOChipInfo
-	err = sys.Ioctl(c.fd, sys.GPIO_GET_CHIPINFO_IOCTL, uintptr(unsafe.Pointer(&arg)))
+	err = c.ioctl(sys.GPIO_GET_CHIPINFO_IOCTL, unsafe.Pointer(&arg))
 	if err != nil {
-		err = fmt.Errorf("get GPIO chip info of %s failed: %w", c.dev, err)
+		err = chipError("get GPIO chip info", c.dev, err)
 		return
 	}
 	info = ChipInfo{
@@ -76,17 +211,28 @@ func (c *Chip) Info() (info ChipInfo, err error) {
 // LineInfo returns the information about a certain GPIO line.
 // Offset is the local line offset on this GPIO chip.
 func (c *Chip) LineInfo(offset uint32) (info LineInfo, err error) {
-	var arg = sys.GPIOLineInfo{LineOffset: offset}
-	err = sys.Ioctl(c.fd, sys.GPIO_GET_LINEINFO_IOCTL, uintptr(unsafe.Pointer(&arg)))
+	info, err = c.lineInfo(offset)
 	if err != nil {
-		err = fmt.Errorf("get GPIO line info %v %v failed: %w", c.dev, offset, err)
+		err = c.lineError("get GPIO line info", []uint32{offset}, err)
 		return
 	}
-	info = LineInfo{
-		Offset:   arg.LineOffset,
-		Name:     sys.Str32(arg.Name),
-		Consumer: sys.Str32(arg.Consumer),
-		flags:    arg.Flags,
+	return
+}
+
+// lineInfo is LineInfo without wrapping the error.
+func (c *Chip) lineInfo(offset uint32) (info LineInfo, err error) {
+	if c.v1 {
+		var arg = sys.GPIOLineInfo{LineOffset: offset}
+		err = c.ioctl(sys.GPIO_GET_LINEINFO_IOCTL, unsafe.Pointer(&arg))
+		if err == nil {
+			info = lineInfoV1(&arg)
+		}
+		return
+	}
+	var arg = sys.GPIOV2LineInfo{Offset: offset}
+	err = c.ioctl(sys.GPIO_V2_GET_LINEINFO_IOCTL, unsafe.Pointer(&arg))
+	if err == nil {
+		info = lineInfoV2(&arg)
 	}
 	return
 }
@@ -102,9 +248,11 @@ func (c *Chip) LineInfo(offset uint32) (info LineInfo, err error) {
 func (c *Chip) requestLines(offsets []uint32, outputDefaultValues []byte, requestFlags uint32, consumer string) (result *Lines, err error) {
 	if len(offsets) > 64 {
 		err = fmt.Errorf("open GPIO lines failed: length of offsets(%v) > 64", len(offsets))
+		return
 	}
 	if len(outputDefaultValues) > 64 {
 		err = fmt.Errorf("open GPIO lines failed: length of default values(%v) > 64", len(outputDefaultValues))
+		return
 	}
 	var numLines = len(offsets)
 	var arg = sys.GPIOHandleRequest{
@@ -115,16 +263,127 @@ func (c *Chip) requestLines(offsets []uint32, outputDefaultValues []byte, reques
 	copy(arg.DefaultValues[:], outputDefaultValues)
 	arg.ConsumerLabel = sys.Char32(consumer)
 
-	err = sys.Ioctl(c.fd, sys.GPIO_GET_LINEHANDLE_IOCTL, uintptr(unsafe.Pointer(&arg)))
+	err = c.ioctl(sys.GPIO_GET_LINEHANDLE_IOCTL, unsafe.Pointer(&arg))
 	if err != nil {
-		err = fmt.Errorf("open GPIO lines %v on %v failed: %w", offsets, c.dev, err)
+		err = c.lineError("open GPIO lines", offsets, err)
 		return
 	}
 
-	result = &Lines{fd: int(arg.Fd), numLines: numLines}
+	result = &Lines{fd: int(arg.Fd), numLines: numLines, offsets: append([]uint32(nil), offsets...), sim: c.sim}
 	return
 }
 
+// requestLinesV2 is the uAPI v2 version of requestLines.
+// Config is the configuration of the requested lines.
+func (c *Chip) requestLinesV2(offsets []uint32, config *sys.GPIOV2LineConfig, consumer string) (result *Lines, err error) {
+	if len(offsets) > sys.GPIO_V2_LINES_MAX {
+		err = fmt.Errorf("open GPIO lines failed: length of offsets(%v) > %v", len(offsets), sys.GPIO_V2_LINES_MAX)
+		return
+	}
+	var numLines = len(offsets)
+	var arg = sys.GPIOV2LineRequest{
+		Config:   *config,
+		NumLines: uint32(numLines),
+	}
+	copy(arg.Offsets[:], offsets)
+	arg.Consumer = sys.Char32(consumer)
+
+	err = c.ioctl(sys.GPIO_V2_GET_LINE_IOCTL, unsafe.Pointer(&arg))
+	if err != nil {
+		err = c.lineError("open GPIO lines", offsets, err)
+		return
+	}
+
+	result = &Lines{fd: int(arg.Fd), numLines: numLines, offsets: append([]uint32(nil), offsets...), v2: true, sim: c.sim}
+	return
+}
+
+// openLines opens lines with uAPI v2 if it is supported by the kernel,
+// and falls back to uAPI v1 otherwise. See requestLines for the parameters.
+// Options are applied to the other parameters.
+func (c *Chip) openLines(offsets []uint32, outputDefaultValues []byte, requestFlags uint32, consumer string, options []LineOption) (*Lines, error) {
+	opts, err := newRequestOptions(LineFlag(requestFlags), outputDefaultValues, consumer, 0, options)
+	if err != nil {
+		return nil, c.lineError("open GPIO lines", offsets, err)
+	}
+	if opts.edges != 0 {
+		return nil, c.lineError("open GPIO lines", offsets, errors.New("edges require RequestLinesWithEvents"))
+	}
+	configs, err := opts.lineConfigs(offsets)
+	if err != nil {
+		return nil, c.lineError("open GPIO lines", offsets, err)
+	}
+	if c.v1 {
+		flags, _, values, err := opts.requestV1(configs)
+		if err != nil {
+			return nil, c.lineError("open GPIO lines", offsets, err)
+		}
+		lines, err := c.requestLines(offsets, values, uint32(flags), opts.consumer)
+		if err != nil {
+			return nil, err
+		}
+		lines.setConfig(configs)
+		return lines, nil
+	}
+	config, err := opts.configV2(configs)
+	if err != nil {
+		return nil, c.lineError("open GPIO lines", offsets, err)
+	}
+	lines, err := c.requestLinesV2(offsets, &config, opts.consumer)
+	if err != nil {
+		return nil, err
+	}
+	lines.setConfig(configs)
+	return lines, nil
+}
+
+// openLinesWithEvents opens input lines with GPIO events. See openLines.
+func (c *Chip) openLinesWithEvents(offsets []uint32, requestFlags, eventFlags uint32, consumer string, options []LineOption) (*LinesWithEvents, error) {
+	if len(offsets) == 0 {
+		return nil, c.lineError("open GPIO lines", offsets, errors.New("no offset"))
+	}
+	opts, err := newRequestOptions(LineFlag(requestFlags), nil, consumer, EventFlag(eventFlags), options)
+	if err != nil {
+		return nil, c.lineError("open GPIO lines", offsets, err)
+	}
+	configs, err := opts.lineConfigs(offsets)
+	if err != nil {
+		return nil, c.lineError("open GPIO lines", offsets, err)
+	}
+	var edges EventFlag
+	for i := range configs {
+		edges |= configs[i].edges
+	}
+	if edges == 0 {
+		return nil, c.lineError("open GPIO lines", offsets, errors.New("no edge, at least one edge is required"))
+	}
+	if c.v1 {
+		if len(offsets) > 1 {
+			return nil, c.lineError("open GPIO lines", offsets, fmt.Errorf("%w: events on multiple lines requires GPIO uAPI v2(Linux 5.10+)", ErrUnsupported))
+		}
+		flags, edges, _, err := opts.requestV1(configs)
+		if err != nil {
+			return nil, c.lineError("open GPIO lines", offsets, err)
+		}
+		line, err := c.newInputLineWithEvents(offsets[0], uint32(flags), uint32(edges), opts.consumer, &opts)
+		if err != nil {
+			return nil, err
+		}
+		line.l.configs = lineConfigsOf(offsets, configs)
+		return &LinesWithEvents{l: (*Lines)(line.l), events: line.events}, nil
+	}
+	config, err := opts.configV2(configs)
+	if err != nil {
+		return nil, c.lineError("open GPIO lines", offsets, err)
+	}
+	lines, err := c.newInputLinesWithEventsV2(offsets, &config, opts.consumer, &opts)
+	if err != nil {
+		return nil, err
+	}
+	lines.l.setConfig(configs)
+	return lines, nil
+}
+
 type LineFlag uint32
 
 const (
@@ -143,6 +402,15 @@ const (
 	// You can think of an open-drain GPIO as behaving like a switch which is either connected to ground or disconnected."
 	OpenDrain  = LineFlag(sys.GPIOHANDLE_REQUEST_OPEN_DRAIN)
 	OpenSource = LineFlag(sys.GPIOHANDLE_REQUEST_OPEN_SOURCE)
+	// PullUp enables the internal pull-up resistor of the line,
+	// so an unconnected input reads high.
+	// PullUp, PullDown and BiasDisabled are mutually exclusive, and require Linux 5.5+.
+	PullUp = LineFlag(sys.GPIOHANDLE_REQUEST_BIAS_PULL_UP)
+	// PullDown enables the internal pull-down resistor of the line,
+	// so an unconnected input reads low.
+	PullDown = LineFlag(sys.GPIOHANDLE_REQUEST_BIAS_PULL_DOWN)
+	// BiasDisabled disables both the internal pull-up and pull-down resistors of the line.
+	BiasDisabled = LineFlag(sys.GPIOHANDLE_REQUEST_BIAS_DISABLE)
 )
 
 // OpenLines opens up to 64 lines on this GPIO chip at once.
@@ -156,17 +424,18 @@ const (
 // Parameter flags is or'ed LineFlag values that will be applied to all quested lines.
 // Parameter consumer is a desired consumer label for the selected GPIO line(s) such
 // as "my-bitbanged-relay".
-func (c *Chip) OpenLines(offsets []uint32, defaultValues []byte, flags LineFlag, consumer string) (*Lines, error) {
-	return c.requestLines(offsets, defaultValues, uint32(flags), consumer)
+// Parameter options are optional configurations such as WithDebounce.
+func (c *Chip) OpenLines(offsets []uint32, defaultValues []byte, flags LineFlag, consumer string, options ...LineOption) (*Lines, error) {
+	return c.openLines(offsets, defaultValues, uint32(flags), consumer, options)
 }
 
 // OpenLine opens a single GPIO line on this chip.
 // It is equivalent to call OpenLines with a single offset and devault value if Output
 // is set.
-func (c *Chip) OpenLine(offset uint32, defaultValue byte, flags LineFlag, consumer string) (line *Line, err error) {
+func (c *Chip) OpenLine(offset uint32, defaultValue byte, flags LineFlag, consumer string, options ...LineOption) (line *Line, err error) {
 	var offsets = [1]uint32{offset}
 	var defaultValues = [1]byte{defaultValue}
-	lines, err := c.requestLines(offsets[:], defaultValues[:], uint32(flags), consumer)
+	lines, err := c.openLines(offsets[:], defaultValues[:], uint32(flags), consumer, options)
 	if err != nil {
 		return
 	}
@@ -183,12 +452,65 @@ const (
 )
 
 // OpenLineWithEvents opens a single GPIO line on this chip for input and GPIO events.
-func (c *Chip) OpenLineWithEvents(offset uint32, flags LineFlag, eventFlags EventFlag, consumer string) (line *LineWithEvent, err error) {
-	if eventFlags == 0 {
-		err = fmt.Errorf("open GPIO line failed: invalid event flags %v, at least one edge is required", eventFlags)
+// Parameter flags is or'ed LineFlag values such as ActiveLow and PullUp.
+// Parameter options are optional configurations such as WithDebounce and WithEventBuffer.
+func (c *Chip) OpenLineWithEvents(offset uint32, flags LineFlag, eventFlags EventFlag, consumer string, options ...LineOption) (line *LineWithEvent, err error) {
+	var offsets = [1]uint32{offset}
+	lines, err := c.openLinesWithEvents(offsets[:], uint32(flags), uint32(eventFlags), consumer, options)
+	if err != nil {
+		return
+	}
+	line = &LineWithEvent{l: (*Line)(lines.l), events: lines.events, clock: lines.clock}
+	return
+}
+
+// OpenLinesWithEvents opens GPIO lines on this chip for input and GPIO events.
+// The events of all the lines are delivered through a single channel, and
+// Event.Offset tells which line an event occurred on.
+// Parameter flags is or'ed LineFlag values such as ActiveLow and PullUp.
+// Parameter options are optional configurations such as WithDebounce and WithEventBuffer.
+// Opening more than one line requires uAPI v2(Linux 5.10+).
+func (c *Chip) OpenLinesWithEvents(offsets []uint32, flags LineFlag, eventFlags EventFlag, consumer string, options ...LineOption) (lines *LinesWithEvents, err error) {
+	return c.openLinesWithEvents(offsets, uint32(flags), uint32(eventFlags), consumer, options)
+}
+
+// RequestLines requests up to 64 lines on this GPIO chip at once, configured by options
+// such as WithOutput, WithBias and WithConsumer. The lines are input by default.
+// Options can be applied to certain lines with ForOffset, and the options are
+// validated before the lines are requested from the kernel.
+func (c *Chip) RequestLines(offsets []uint32, options ...LineOption) (*Lines, error) {
+	return c.openLines(offsets, nil, uint32(Input), "", options)
+}
+
+// RequestLine requests a single GPIO line on this chip. See RequestLines.
+func (c *Chip) RequestLine(offset uint32, options ...LineOption) (line *Line, err error) {
+	var offsets = [1]uint32{offset}
+	lines, err := c.openLines(offsets[:], nil, uint32(Input), "", options)
+	if err != nil {
 		return
 	}
-	return newInputLineWithEvents(c.fd, offset, uint32(flags), uint32(eventFlags), consumer)
+	line = (*Line)(lines)
+	return
+}
+
+// RequestLinesWithEvents requests GPIO lines on this chip for input and GPIO events,
+// configured by options like RequestLines. Events are generated on both edges
+// unless set by WithEdges. See OpenLinesWithEvents for the events.
+// Requesting more than one line requires uAPI v2(Linux 5.10+).
+func (c *Chip) RequestLinesWithEvents(offsets []uint32, options ...LineOption) (*LinesWithEvents, error) {
+	return c.openLinesWithEvents(offsets, uint32(Input), uint32(BothEdges), "", options)
+}
+
+// RequestLineWithEvents requests a single GPIO line on this chip for input and GPIO events.
+// See RequestLinesWithEvents.
+func (c *Chip) RequestLineWithEvents(offset uint32, options ...LineOption) (line *LineWithEvent, err error) {
+	var offsets = [1]uint32{offset}
+	lines, err := c.openLinesWithEvents(offsets[:], uint32(Input), uint32(BothEdges), "", options)
+	if err != nil {
+		return
+	}
+	line = &LineWithEvent{l: (*Line)(lines.l), events: lines.events, clock: lines.clock}
+	return
 }
 
 // LineInfo represents the information about a certain GPIO line
@@ -203,30 +525,90 @@ type LineInfo struct {
 	// whatever is using it, will be empty if there is no current user but may
 	// also be empty if the consumer doesn't set this up.
 	Consumer string
-	flags    uint32
+	// The debounce period of this line, 0 if debouncing is disabled.
+	// Always 0 without uAPI v2(Linux 5.10+).
+	DebouncePeriod time.Duration
+	flags          uint64 // uAPI v2 line flags.
+}
+
+// lineInfoV2 converts uAPI v2 gpio_v2_line_info to LineInfo.
+func lineInfoV2(arg *sys.GPIOV2LineInfo) (info LineInfo) {
+	info = LineInfo{
+		Offset:   arg.Offset,
+		Name:     sys.Str32(arg.Name),
+		Consumer: sys.Str32(arg.Consumer),
+		flags:    arg.Flags,
+	}
+	for i := uint32(0); i < arg.NumAttrs && i < sys.GPIO_V2_LINE_NUM_ATTRS_MAX; i++ {
+		attr := &arg.Attrs[i]
+		if attr.ID == sys.GPIO_V2_LINE_ATTR_ID_DEBOUNCE {
+			info.DebouncePeriod = time.Duration(attr.DebouncePeriodUs()) * time.Microsecond
+		}
+	}
+	return
+}
+
+// lineInfoV1 converts uAPI v1 gpioline_info to LineInfo.
+func lineInfoV1(arg *sys.GPIOLineInfo) LineInfo {
+	return LineInfo{
+		Offset:   arg.LineOffset,
+		Name:     sys.Str32(arg.Name),
+		Consumer: sys.Str32(arg.Consumer),
+		flags:    lineInfoFlagsV2(arg.Flags),
+	}
 }
 
 // Kernel returns whether the GPIO line is used by the kernel.
 func (info *LineInfo) Kernel() bool {
-	return info.flags&sys.GPIOLINE_FLAG_KERNEL != 0
+	return info.flags&sys.GPIO_V2_LINE_FLAG_USED != 0
 }
 
 // Output returns whether the GPIO line is output.
 func (info *LineInfo) Output() bool {
-	return info.flags&sys.GPIOLINE_FLAG_IS_OUT != 0
+	return info.flags&sys.GPIO_V2_LINE_FLAG_OUTPUT != 0
 }
 
 // ActiveLow returns whether the GPIO line is configured as active low.
 func (info *LineInfo) ActiveLow() bool {
-	return info.flags&sys.GPIOLINE_FLAG_ACTIVE_LOW != 0
+	return info.flags&sys.GPIO_V2_LINE_FLAG_ACTIVE_LOW != 0
 }
 
 // ActiveLow returns whether the GPIO line is configured as open-drain.
 func (info *LineInfo) OpenDrain() bool {
-	return info.flags&sys.GPIOLINE_FLAG_OPEN_DRAIN != 0
+	return info.flags&sys.GPIO_V2_LINE_FLAG_OPEN_DRAIN != 0
 }
 
 // ActiveLow returns whether the GPIO line is configured as open-source.
 func (info *LineInfo) OpenSource() bool {
-	return info.flags&sys.GPIOLINE_FLAG_OPEN_SOURCE != 0
+	return info.flags&sys.GPIO_V2_LINE_FLAG_OPEN_SOURCE != 0
+}
+
+// PullUp returns whether the pull-up bias of the GPIO line is enabled.
+func (info *LineInfo) PullUp() bool {
+	return info.flags&sys.GPIO_V2_LINE_FLAG_BIAS_PULL_UP != 0
+}
+
+// PullDown returns whether the pull-down bias of the GPIO line is enabled.
+func (info *LineInfo) PullDown() bool {
+	return info.flags&sys.GPIO_V2_LINE_FLAG_BIAS_PULL_DOWN != 0
+}
+
+// BiasDisabled returns whether the bias of the GPIO line is disabled.
+// If none of PullUp, PullDown and BiasDisabled returns true, the bias is unknown
+// or left as is.
+func (info *LineInfo) BiasDisabled() bool {
+	return info.flags&sys.GPIO_V2_LINE_FLAG_BIAS_DISABLED != 0
+}
+
+// EventClock returns the clock used to timestamp the events of the GPIO line.
+// Always EventClockMonotonic without uAPI v2(Linux 5.10+).
+func (info *LineInfo) EventClock() EventClock {
+	switch {
+	case info.flags&sys.GPIO_V2_LINE_FLAG_EVENT_CLOCK_REALTIME != 0:
+		return EventClockRealtime
+	case info.flags&sys.GPIO_V2_LINE_FLAG_EVENT_CLOCK_HTE != 0:
+		return EventClockHTE
+	default:
+		return EventClockMonotonic
+	}
 }
@@ -1,10 +1,13 @@
 package gpio_test
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	. "github.com/mkch/asserting"
 	"github.com/mkch/gpio"
@@ -31,6 +34,29 @@ func TestOpenChip(t1 *testing.T) {
 	t.AssertNoError(chip.Close())
 }
 
+func TestOpenChipByLabel(t1 *testing.T) {
+	t := NewTB(t1)
+	chip, err := gpio.OpenChip(chipDev)
+	t.Assert(ValueErrorFatal(chip, err), NotEquals(nil).SetFatal())
+	info, err := chip.Info()
+	t.AssertNoError(err)
+	t.AssertNoError(chip.Close())
+
+	path, err := gpio.ChipPath(filepath.Join("/dev", chipDev))
+	t.AssertNoError(err)
+	t.AssertEqual(path, filepath.Join("/dev", chipDev))
+
+	if info.Label == "" {
+		t.Skip("chip has no label")
+	}
+	chip, err = gpio.OpenChip(info.Label)
+	t.Assert(ValueErrorFatal(chip, err), NotEquals(nil).SetFatal())
+	defer func() { t.AssertNoError(chip.Close()) }()
+	info2, err := chip.Info()
+	t.AssertNoError(err)
+	t.AssertEqual(info2.Label, info.Label)
+}
+
 func TestChipInfo(t1 *testing.T) {
 	t := NewTB(t1)
 	chip, err := gpio.OpenChip(chipDev)
@@ -68,6 +94,7 @@ func TestOpenInputLine(t1 *testing.T) {
 	type wantInfo struct {
 		consumer  string
 		activeLow bool
+		pullUp    bool
 	}
 	type testCase struct {
 		name     string
@@ -96,6 +123,17 @@ func TestOpenInputLine(t1 *testing.T) {
 				activeLow: true,
 			},
 		},
+		testCase{
+			name: "pull-up",
+			args: args{
+				flags:    gpio.Input | gpio.PullUp,
+				consumer: consumer,
+			},
+			wantInfo: wantInfo{
+				consumer: consumer,
+				pullUp:   true,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t1.Run(tt.name, func(t1 *testing.T) {
@@ -110,6 +148,8 @@ func TestOpenInputLine(t1 *testing.T) {
 			t.AssertEqual(gotInfo.ActiveLow(), tt.wantInfo.activeLow)
 			t.AssertEqual(gotInfo.OpenDrain(), false)
 			t.AssertEqual(gotInfo.OpenSource(), false)
+			t.AssertEqual(gotInfo.PullUp(), tt.wantInfo.pullUp)
+			t.AssertEqual(gotInfo.PullDown(), false)
 
 			t.AssertNoError(line.Close())
 		})
@@ -192,3 +232,357 @@ func TestOpenOutputLine(t1 *testing.T) {
 		})
 	}
 }
+
+func TestLineSetConfig(t1 *testing.T) {
+	t := NewTB(t1)
+	chip, err := gpio.OpenChip(chipDev)
+	t.Assert(ValueErrorFatal(chip, err), NotEquals(nil).SetFatal())
+	defer func() { t.AssertNoError(chip.Close()) }()
+
+	line, err := chip.OpenLine(uint32(outputLine), 1, gpio.Output, "a")
+	t.Assert(ValueError(line, err), NotEquals(nil).SetFatal())
+	defer func() { t.AssertNoError(line.Close()) }()
+
+	t.AssertNoError(line.SetConfig(gpio.Input|gpio.PullUp, 0))
+	gotInfo, err := chip.LineInfo(uint32(outputLine))
+	t.AssertNoError(err)
+	t.AssertEqual(gotInfo.Output(), false)
+	t.AssertEqual(gotInfo.PullUp(), true)
+	t.AssertEqual(gotInfo.Consumer, "a")
+
+	t.AssertNoError(line.SetConfig(gpio.Output|gpio.ActiveLow, 1))
+	gotInfo, err = chip.LineInfo(uint32(outputLine))
+	t.AssertNoError(err)
+	t.AssertEqual(gotInfo.Output(), true)
+	t.AssertEqual(gotInfo.ActiveLow(), true)
+	t.AssertEqual(gotInfo.PullUp(), false)
+	t.Assert(ValueError(line.Value()), Equals(byte(1)))
+}
+
+// blink is a driver working with both gpio and gpiosysfs.
+func blink(pin interface {
+	gpio.Reader
+	gpio.Writer
+	gpio.DirectionSetter
+}) (err error) {
+	if err = pin.SetOutput(1); err != nil {
+		return
+	}
+	if err = pin.SetValue(0); err != nil {
+		return
+	}
+	v, err := pin.Value()
+	if err != nil {
+		return
+	}
+	if v != 0 {
+		return errors.New("blink failed")
+	}
+	return pin.SetInput()
+}
+
+func TestLineDirection(t1 *testing.T) {
+	t := NewTB(t1)
+	chip, err := gpio.OpenChip(chipDev)
+	t.Assert(ValueErrorFatal(chip, err), NotEquals(nil).SetFatal())
+	defer func() { t.AssertNoError(chip.Close()) }()
+
+	line, err := chip.RequestLine(uint32(outputLine), gpio.WithConsumer("a"), gpio.WithBias(gpio.PullUp))
+	t.Assert(ValueError(line, err), NotEquals(nil).SetFatal())
+	defer func() { t.AssertNoError(line.Close()) }()
+
+	t.AssertNoError(line.SetOutput(1))
+	gotInfo, err := chip.LineInfo(uint32(outputLine))
+	t.AssertNoError(err)
+	t.AssertTrue(gotInfo.Output() && gotInfo.PullUp())
+	t.Assert(ValueError(line.Value()), Equals(byte(1)))
+	t.AssertEqual(line.Config().Flags, gpio.Output|gpio.PullUp)
+
+	t.AssertNoError(line.SetInput())
+	gotInfo, err = chip.LineInfo(uint32(outputLine))
+	t.AssertNoError(err)
+	t.AssertTrue(!gotInfo.Output() && gotInfo.PullUp())
+
+	t.AssertNoError(blink(line))
+}
+
+func TestWatchLineInfo(t1 *testing.T) {
+	t := NewTB(t1)
+	chip, err := gpio.OpenChip(chipDev)
+	t.Assert(ValueErrorFatal(chip, err), NotEquals(nil).SetFatal())
+	defer func() { t.AssertNoError(chip.Close()) }()
+
+	info, err := chip.WatchLineInfo(uint32(outputLine))
+	t.AssertNoError(err)
+	t.AssertEqual(info.Offset, uint32(outputLine))
+
+	line, err := chip.OpenLine(uint32(outputLine), 0, gpio.Output, "watched")
+	t.Assert(ValueError(line, err), NotEquals(nil).SetFatal())
+	change := <-chip.LineInfoChanges()
+	t.AssertEqual(change.Type, gpio.LineRequested)
+	t.AssertEqual(change.Info.Consumer, "watched")
+
+	t.AssertNoError(line.Close())
+	change = <-chip.LineInfoChanges()
+	t.AssertEqual(change.Type, gpio.LineReleased)
+
+	t.AssertNoError(chip.UnwatchLineInfo(uint32(outputLine)))
+}
+
+func TestDebounce(t1 *testing.T) {
+	t := NewTB(t1)
+	chip, err := gpio.OpenChip(chipDev)
+	t.Assert(ValueErrorFatal(chip, err), NotEquals(nil).SetFatal())
+	defer func() { t.AssertNoError(chip.Close()) }()
+
+	line, err := chip.OpenLineWithEvents(uint32(inputLine), gpio.Input, gpio.BothEdges, "a", gpio.WithDebounce(5*time.Millisecond))
+	t.Assert(ValueError(line, err), NotEquals(nil).SetFatal())
+	defer func() { t.AssertNoError(line.Close()) }()
+
+	gotInfo, err := chip.LineInfo(uint32(inputLine))
+	t.AssertNoError(err)
+	t.AssertEqual(gotInfo.DebouncePeriod, 5*time.Millisecond)
+}
+
+func TestOpenLinesWithEvents(t1 *testing.T) {
+	t := NewTB(t1)
+	chip, err := gpio.OpenChip(chipDev)
+	t.Assert(ValueErrorFatal(chip, err), NotEquals(nil).SetFatal())
+	defer func() { t.AssertNoError(chip.Close()) }()
+
+	_, err = chip.OpenLinesWithEvents(nil, gpio.Input, gpio.BothEdges, "a")
+	t.AssertNotEqual(err, nil)
+
+	lines, err := chip.OpenLinesWithEvents([]uint32{uint32(inputLine)}, gpio.Input, gpio.BothEdges, "a")
+	t.Assert(ValueError(lines, err), NotEquals(nil).SetFatal())
+	values, err := lines.Values()
+	t.AssertNoError(err)
+	t.AssertEqual(len(values), 1)
+
+	gotInfo, err := chip.LineInfo(uint32(inputLine))
+	t.AssertNoError(err)
+	t.AssertEqual(gotInfo.Consumer, "a")
+	t.AssertTrue(!gotInfo.Output())
+
+	t.AssertNoError(lines.Close())
+	_, ok := <-lines.Events()
+	t.AssertTrue(!ok)
+}
+
+func TestFindLineOnChip(t1 *testing.T) {
+	t := NewTB(t1)
+	chip, err := gpio.OpenChip(chipDev)
+	t.Assert(ValueErrorFatal(chip, err), NotEquals(nil).SetFatal())
+	defer func() { t.AssertNoError(chip.Close()) }()
+
+	info, err := chip.LineInfo(uint32(inputLine))
+	t.AssertNoError(err)
+	if info.Name == "" {
+		t.Skip("input line has no name")
+	}
+	dev, offset, err := gpio.FindLine(info.Name)
+	var ambiguous *gpio.AmbiguousLineError
+	if errors.As(err, &ambiguous) {
+		t.Skipf("input line name %q is not unique", info.Name)
+	}
+	t.AssertNoError(err)
+	t.AssertEqual(dev, chipDev)
+	t.AssertEqual(offset, uint32(inputLine))
+}
+
+func TestValuesMasked(t1 *testing.T) {
+	t := NewTB(t1)
+	chip, err := gpio.OpenChip(chipDev)
+	t.Assert(ValueErrorFatal(chip, err), NotEquals(nil).SetFatal())
+	defer func() { t.AssertNoError(chip.Close()) }()
+
+	lines, err := chip.OpenLines([]uint32{uint32(outputLine)}, []byte{0}, gpio.Output, "a")
+	t.Assert(ValueError(lines, err), NotEquals(nil).SetFatal())
+	defer func() { t.AssertNoError(lines.Close()) }()
+
+	t.AssertNoError(lines.SetValuesMasked(0b1, 0b1))
+	bits, err := lines.ValuesMasked(0b1)
+	t.AssertNoError(err)
+	t.AssertEqual(bits, uint64(0b1))
+	t.AssertNoError(lines.SetValuesMasked(0b1, 0))
+	bits, err = lines.ValuesMasked(0b1)
+	t.AssertNoError(err)
+	t.AssertEqual(bits, uint64(0))
+}
+
+func TestRequestLines(t1 *testing.T) {
+	t := NewTB(t1)
+	chip, err := gpio.OpenChip(chipDev)
+	t.Assert(ValueErrorFatal(chip, err), NotEquals(nil).SetFatal())
+	defer func() { t.AssertNoError(chip.Close()) }()
+
+	lines, err := chip.RequestLines([]uint32{uint32(inputLine), uint32(outputLine)},
+		gpio.WithConsumer("a"),
+		gpio.ForOffset(uint32(outputLine), gpio.WithOutput(1)))
+	t.Assert(ValueError(lines, err), NotEquals(nil).SetFatal())
+	defer func() { t.AssertNoError(lines.Close()) }()
+
+	info, err := chip.LineInfo(uint32(inputLine))
+	t.AssertNoError(err)
+	t.AssertTrue(!info.Output())
+	t.AssertEqual(info.Consumer, "a")
+	info, err = chip.LineInfo(uint32(outputLine))
+	t.AssertNoError(err)
+	t.AssertTrue(info.Output())
+	bits, err := lines.ValuesMasked(0b10)
+	t.AssertNoError(err)
+	t.AssertEqual(bits, uint64(0b10))
+	t.AssertEqualSlice(lines.Config(), []gpio.LineConfig{
+		{Offset: uint32(inputLine), Flags: gpio.Input},
+		{Offset: uint32(outputLine), Flags: gpio.Output},
+	})
+
+	// Swap the directions and set active-low of the input line only.
+	t.AssertNoError(lines.Reconfigure(gpio.Output, nil,
+		gpio.ForOffset(uint32(inputLine), gpio.WithActiveLow(true)),
+		gpio.ForOffset(uint32(outputLine), gpio.WithInput())))
+	t.AssertEqualSlice(lines.Config(), []gpio.LineConfig{
+		{Offset: uint32(inputLine), Flags: gpio.Output | gpio.ActiveLow},
+		{Offset: uint32(outputLine), Flags: gpio.Input},
+	})
+	info, err = chip.LineInfo(uint32(inputLine))
+	t.AssertNoError(err)
+	t.AssertTrue(info.Output() && info.ActiveLow())
+
+	_, err = chip.RequestLine(uint32(outputLine), gpio.WithDrive(gpio.OpenDrain))
+	t.Assert(err, NotEquals(nil))
+}
+
+func TestBitsAllocs(t1 *testing.T) {
+	t := NewTB(t1)
+	chip, err := gpio.OpenChip(chipDev)
+	t.Assert(ValueErrorFatal(chip, err), NotEquals(nil).SetFatal())
+	defer func() { t.AssertNoError(chip.Close()) }()
+
+	lines, err := chip.OpenLines([]uint32{uint32(outputLine)}, []byte{0}, gpio.Output, "a")
+	t.Assert(ValueError(lines, err), NotEquals(nil).SetFatal())
+	defer func() { t.AssertNoError(lines.Close()) }()
+
+	t.AssertNoError(lines.SetBits(1))
+	bits, err := lines.Bits()
+	t.AssertNoError(err)
+	t.AssertEqual(bits, uint64(1))
+	var values = make([]byte, 1)
+	t.AssertNoError(lines.ValuesInto(values))
+	t.AssertEqualSlice(values, []byte{1})
+	t.Assert(lines.ValuesInto(nil), NotEquals(nil))
+
+	allocs := testing.AllocsPerRun(100, func() {
+		lines.SetBits(0)
+		lines.Bits()
+		lines.ValuesInto(values)
+	})
+	t.AssertEqual(allocs, float64(0))
+}
+
+func BenchmarkBits(b *testing.B) {
+	chip, err := gpio.OpenChip(chipDev)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer chip.Close()
+	lines, err := chip.OpenLines([]uint32{uint32(inputLine)}, nil, gpio.Input, "a")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer lines.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := lines.Bits(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSetBits(b *testing.B) {
+	chip, err := gpio.OpenChip(chipDev)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer chip.Close()
+	lines, err := chip.OpenLines([]uint32{uint32(outputLine)}, []byte{0}, gpio.Output, "a")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer lines.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := lines.SetBits(uint64(i & 1)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkValuesInto(b *testing.B) {
+	chip, err := gpio.OpenChip(chipDev)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer chip.Close()
+	lines, err := chip.OpenLines([]uint32{uint32(inputLine)}, nil, gpio.Input, "a")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer lines.Close()
+
+	var values = make([]byte, 1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := lines.ValuesInto(values); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkValues(b *testing.B) {
+	chip, err := gpio.OpenChip(chipDev)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer chip.Close()
+	lines, err := chip.OpenLines([]uint32{uint32(inputLine)}, nil, gpio.Input, "a")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer lines.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := lines.Values(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestWaitValue(t1 *testing.T) {
+	t := NewTB(t1)
+	chip, err := gpio.OpenChip(chipDev)
+	t.Assert(ValueErrorFatal(chip, err), NotEquals(nil).SetFatal())
+	defer func() { t.AssertNoError(chip.Close()) }()
+
+	line, err := chip.OpenLineWithEvents(uint32(inputLine), gpio.Input, gpio.BothEdges, "a")
+	t.Assert(ValueError(line, err), NotEquals(nil).SetFatal())
+	defer func() { t.AssertNoError(line.Close()) }()
+
+	value, err := line.Value()
+	t.AssertNoError(err)
+	e, err := line.WaitValue(context.Background(), value)
+	t.AssertNoError(err)
+	t.AssertEqual(e.RisingEdge, value == 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	_, err = line.WaitValue(ctx, 1-value)
+	t.AssertEqual(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,87 @@
+package gpiosysfs_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/mkch/asserting"
+	"github.com/mkch/gpio"
+	"github.com/mkch/gpio/gpiosysfs"
+	"github.com/mkch/gpio/sysfsadapter"
+)
+
+func TestEdgeWatcher(t1 *testing.T) {
+	t := NewTB(t1)
+	fs := gpiosysfs.NewFakeSysfs(t)
+	defer fs.Close()
+	pin, edge := fs.OpenPinWithEvents(t, 4)
+	defer pin.Close()
+	var w gpio.EdgeWatcher = sysfsadapter.EdgeWatcher(pin)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		edge(1)
+	}()
+	event, err := w.WaitEdge(ctx, gpio.RisingEdge)
+	t.AssertNoError(err)
+	t.AssertEqual(event.RisingEdge, true)
+	value, err := w.Value()
+	t.AssertNoError(err)
+	t.AssertEqual(value, byte(1))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		edge(0)
+	}()
+	event, err = w.WaitEdge(ctx, gpio.BothEdges)
+	t.AssertNoError(err)
+	t.AssertEqual(event.RisingEdge, false)
+
+	_, err = w.WaitEdge(ctx, 0)
+	t.Assert(err, NotEquals(nil))
+	_, err = w.WaitEdge(ctx, gpio.BothEdges<<1)
+	t.Assert(err, NotEquals(nil))
+}
+
+func TestEdgeWatcherWaitValue(t1 *testing.T) {
+	t := NewTB(t1)
+	fs := gpiosysfs.NewFakeSysfs(t)
+	defer fs.Close()
+	pin, edge := fs.OpenPinWithEvents(t, 4, gpiosysfs.WithEventBuffer(16, gpiosysfs.OverflowDropOldest))
+	defer pin.Close()
+	var w gpio.EdgeWatcher = sysfsadapter.EdgeWatcher(pin)
+
+	// Already the value.
+	event, err := w.WaitValue(context.Background(), 0)
+	t.AssertNoError(err)
+	t.AssertEqual(event.RisingEdge, false)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		edge(1)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	event, err = w.WaitValue(ctx, 1)
+	t.AssertNoError(err)
+	t.AssertEqual(event.RisingEdge, true)
+
+	// Queued falling and rising edges, which are already reflected by the value.
+	for i, value := range []byte{0, 1, 0} {
+		edge(value)
+		for start := time.Now(); len(w.Events()) <= i; time.Sleep(time.Millisecond) {
+			if time.Since(start) > time.Second {
+				t.Fatal("event not queued")
+			}
+		}
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = w.WaitValue(ctx, 1)
+	t.AssertTrue(errors.Is(err, context.DeadlineExceeded))
+	t.AssertNoError(w.Err())
+}
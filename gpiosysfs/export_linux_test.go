@@ -0,0 +1,62 @@
+package gpiosysfs
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+
+	. "github.com/mkch/asserting"
+	"github.com/mkch/gpio/internal/fdevents"
+	"golang.org/x/sys/unix"
+)
+
+// Exported for the external tests of package gpiosysfs_test.
+
+// FakeSysfs is fakeSysfs for the external tests.
+type FakeSysfs = fakeSysfs
+
+// NewFakeSysfs is newFakeSysfs for the external tests.
+func NewFakeSysfs(t TB) *FakeSysfs {
+	return newFakeSysfs(t)
+}
+
+// OpenPinWithEvents opens pin #n like OpenPinWithEvents, but the events are
+// triggered by the returned function edge, which changes the value of the pin,
+// because a fake value file, which is a regular file, can't be polled.
+func (fs *FakeSysfs) OpenPinWithEvents(t TB, n int, options ...PinOption) (pin *PinWithEvent, edge func(value byte)) {
+	var opts = pinOptions{eventBufferSize: 1, overflowPolicy: OverflowDropOldest}
+	for _, option := range options {
+		option(&opts)
+	}
+	p, err := OpenPin(n)
+	t.AssertNoError(err)
+	t.AssertTrue(fs.exported(n))
+	t.AssertNoError(p.SetDirection(In))
+	var pipe [2]int
+	t.AssertNoError(unix.Pipe2(pipe[:], unix.O_CLOEXEC))
+	write := os.NewFile(uintptr(pipe[1]), "pipe")
+	fs.closers = append(fs.closers, write)
+	valueFd, err := unix.Open(p.value.Path, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	t.AssertNoError(err)
+	fs.closers = append(fs.closers, os.NewFile(uintptr(valueFd), p.value.Path))
+	events, err := fdevents.NewBuffered(pipe[0], true, unix.EPOLLIN, func(fd int) (*fdevents.Event, error) {
+		var buf [1]byte
+		if _, err := unix.Read(fd, buf[:]); err != nil {
+			return nil, err
+		}
+		return readEvent(valueFd)
+	}, opts.eventBufferSize, opts.overflowPolicy)
+	t.AssertNoError(err)
+	pin = &PinWithEvent{Pin: p, events: events}
+	value, err := os.OpenFile(filepath.Join(fs.root, "gpio"+strconv.Itoa(n), "value"), os.O_WRONLY, 0)
+	t.AssertNoError(err)
+	fs.closers = append(fs.closers, value)
+	edge = func(v byte) {
+		// Written in place, so the value is never read empty.
+		_, err := value.WriteAt([]byte{'0' + v, '\n'}, 0)
+		t.AssertNoError(err)
+		_, err = write.Write([]byte{0})
+		t.AssertNoError(err)
+	}
+	return
+}
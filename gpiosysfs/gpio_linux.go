@@ -2,6 +2,7 @@ package gpiosysfs
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -22,14 +23,19 @@ type Chip struct {
 	Ngpio int    // How many GPIOs this chip manges. The GPIOs managed by this chip are in the range of Base to Base + ngpio - 1.
 }
 
-const gpioPath = "/sys/class/gpio"
+// Root is the directory of the GPIO sysfs interface, in which Controllers,
+// Controller, OpenPin and OpenPinWithEvents look for the controllers and pins.
+// It should only be changed before calling any of them, for example to a
+// temporary directory in tests.
+var Root = "/sys/class/gpio"
 
 // Controllers returns all GPIO controllers available.
 func Controllers() (chips []Chip, err error) {
-	dir, err := os.Open(gpioPath)
+	dir, err := os.Open(Root)
 	if err != nil {
 		return
 	}
+	defer dir.Close()
 	children, err := dir.Readdir(-1)
 	if err != nil {
 		return
@@ -39,7 +45,7 @@ func Controllers() (chips []Chip, err error) {
 	for _, child := range children {
 		if strings.HasPrefix(child.Name(), "gpiochip") {
 			var chip Chip
-			chip, err = newController(filepath.Join(gpioPath, child.Name()))
+			chip, err = newController(filepath.Join(Root, child.Name()))
 			if err != nil {
 				return
 			}
@@ -51,10 +57,7 @@ func Controllers() (chips []Chip, err error) {
 
 // Controller returns the GPIO controller #n.
 func Controller(n int) (Chip, error) {
-	filepath.Join()
-	var gpiochip = []byte("gpiochip  ")[:len("gpiochip")]
-	gpiochipN := strconv.AppendInt(gpiochip, int64(n), 10)
-	return newController(filepath.Join(gpioPath, string(gpiochipN)))
+	return newController(filepath.Join(Root, "gpiochip"+strconv.Itoa(n)))
 }
 
 func newController(chipDir string) (chip Chip, err error) {
@@ -78,7 +81,7 @@ func newController(chipDir string) (chip Chip, err error) {
 	}
 	chip.Label = trimNewlines(buf)
 
-	buf, err = ioutil.ReadFile(chipDir + "ngpio")
+	buf, err = ioutil.ReadFile(filepath.Join(chipDir, "ngpio"))
 	if err != nil {
 		return
 	}
@@ -99,16 +102,16 @@ type Pin struct {
 }
 
 // OpenPin opens the GPIO pin #n for IO.
+// The pin is exported if not exported yet.
 func OpenPin(n int) (pin *Pin, err error) {
-	dir := fmt.Sprintf("/sys/class/gpio/gpio%d", n)
+	dir := filepath.Join(Root, "gpio"+strconv.Itoa(n))
 	fi, err := os.Stat(dir)
 	if err != nil {
 		if !os.IsNotExist(err) {
 			err = fmt.Errorf("failed to open pin #%v: %w", n, err)
 			return
 		}
-		const exportPath = "/sys/class/gpio/export"
-		err = writeExisting(exportPath, strconv.Itoa(n))
+		err = writeExisting(filepath.Join(Root, "export"), strconv.Itoa(n))
 		if err != nil {
 			err = fmt.Errorf("failed to open pin #%v: %w", n, err)
 			return
@@ -131,7 +134,7 @@ func OpenPin(n int) (pin *Pin, err error) {
 // Close closes the pin.
 func (pin *Pin) Close() (err error) {
 	close(pin.cancelInterrupt)
-	err = writeExisting("/sys/class/gpio/unexport", strconv.Itoa(pin.n))
+	err = writeExisting(filepath.Join(Root, "unexport"), strconv.Itoa(pin.n))
 	if err != nil {
 		err = fmt.Errorf("failed to close pin #%v: %w", pin.n, err)
 	}
@@ -188,6 +191,20 @@ func (pin *Pin) SetDirection(direction Direction) (err error) {
 	return
 }
 
+// SetInput configures the pin as input.
+func (pin *Pin) SetInput() error {
+	return pin.SetDirection(In)
+}
+
+// SetOutput configures the pin as output, and initializes it to value,
+// 1 for high and 0 for low, without glitch.
+func (pin *Pin) SetOutput(value byte) error {
+	if value == 0 {
+		return pin.SetDirection(OutLow)
+	}
+	return pin.SetDirection(OutHigh)
+}
+
 // Must be greater or equal to the max length of Edge and Direction constants.
 const strBufLen = 16
 
@@ -201,6 +218,7 @@ func (pin *Pin) Direction() (dir Direction, err error) {
 			err = wrapPinError(pin, "get direction", err)
 			return
 		}
+		err = nil
 	}
 	dir = Direction(trimNewlines(buf[:n]))
 	return
@@ -240,6 +258,7 @@ func (pin *Pin) Edge() (edge Edge, err error) {
 			err = wrapPinError(pin, "get edge", err)
 			return
 		}
+		err = nil
 	}
 	edge = Edge(trimNewlines(buf[:n]))
 	return
@@ -274,11 +293,12 @@ func (pin *Pin) SetValue(value byte) (err error) {
 }
 
 // ActiveLow returns whether the pin is configured as active low.
-func (pin *Pin) ActiveLow(value bool, err error) {
+func (pin *Pin) ActiveLow() (value bool, err error) {
 	var buf [1]byte
 	_, err = pin.activeLow.ReadAt0(buf[:])
 	if err != nil {
 		err = wrapPinError(pin, "get activelow", err)
+		return
 	}
 	value = buf[0] == '1'
 	return
@@ -303,8 +323,35 @@ type PinWithEvent struct {
 	events *fdevents.FdEvents
 }
 
+// PinOption is an optional configuration of OpenPinWithEvents.
+type PinOption func(opts *pinOptions)
+
+type pinOptions struct {
+	eventBufferSize int
+	overflowPolicy  OverflowPolicy
+}
+
+// WithEventBuffer sets the buffer size of the event channel, and what to do
+// with a new event when the channel is full.
+// The default is a buffer of 1 with OverflowDropOldest, which only keeps the latest event.
+func WithEventBuffer(size int, policy OverflowPolicy) PinOption {
+	return func(opts *pinOptions) {
+		opts.eventBufferSize = size
+		opts.overflowPolicy = policy
+	}
+}
+
 // OpenPinWithEvents opens a GPIO pin for input and GPIO events.
-func OpenPinWithEvents(n int) (pin *PinWithEvent, err error) {
+// Parameter options are optional configurations such as WithEventBuffer.
+func OpenPinWithEvents(n int, options ...PinOption) (pin *PinWithEvent, err error) {
+	var opts = pinOptions{eventBufferSize: 1, overflowPolicy: OverflowDropOldest}
+	for _, option := range options {
+		option(&opts)
+	}
+	if opts.eventBufferSize < 1 {
+		err = fmt.Errorf("failed to open pin #%v: invalid event buffer size %v", n, opts.eventBufferSize)
+		return
+	}
 	p, err := OpenPin(n)
 	if err != nil {
 		return
@@ -320,13 +367,7 @@ func OpenPinWithEvents(n int) (pin *PinWithEvent, err error) {
 		return
 	}
 
-	events, err := fdevents.New(fd, true, unix.EPOLLPRI|unix.EPOLLERR, func(fd int) *fdevents.Event {
-		v, err := p.Value()
-		if err != nil {
-			panic(fmt.Errorf("failed to read GPIO event: %w", err))
-		}
-		return &fdevents.Event{RisingEdge: v == 1, Time: time.Now()}
-	})
+	events, err := fdevents.NewBuffered(fd, true, unix.EPOLLPRI|unix.EPOLLERR, readEvent, opts.eventBufferSize, opts.overflowPolicy)
 	if err != nil {
 		return
 	}
@@ -339,9 +380,19 @@ func OpenPinWithEvents(n int) (pin *PinWithEvent, err error) {
 	return
 }
 
+// readEvent reads the polled value file fd, and returns the event of the edge.
+// The fd is read instead of Value, which is not safe for concurrent use.
+func readEvent(fd int) (*fdevents.Event, error) {
+	var buf [1]byte
+	if _, err := unix.Pread(fd, buf[:], 0); err != nil {
+		return nil, fmt.Errorf("failed to read GPIO event: %w", err)
+	}
+	return &fdevents.Event{RisingEdge: buf[0] != '0', Time: time.Now()}, nil
+}
+
 func (pin *PinWithEvent) Close() (err error) {
 	// Close pin.events first.
-	// pin.Pin is still used by pin.events before pin.events is closed.
+	// No event is read after pin.Pin is closed and unexported.
 	err1 := pin.events.Close()
 	err2 := pin.Pin.Close()
 	if err1 != nil {
@@ -352,15 +403,95 @@ func (pin *PinWithEvent) Close() (err error) {
 
 type Event = fdevents.Event
 
+// OverflowPolicy decides what to do with a new event when the event channel is full.
+// See WithEventBuffer.
+type OverflowPolicy = fdevents.OverflowPolicy
+
+const (
+	// OverflowDropOldest discards the oldest unread event in the channel to make room.
+	// With a channel buffer of 1, only the latest event is kept.
+	OverflowDropOldest = fdevents.OverflowDropOldest
+	// OverflowDropNewest discards the new event.
+	OverflowDropNewest = fdevents.OverflowDropNewest
+	// OverflowBlock waits until there is room in the channel.
+	OverflowBlock = fdevents.OverflowBlock
+	// OverflowError closes the channel, and Err returns ErrOverflow.
+	OverflowError = fdevents.OverflowError
+)
+
+// ErrOverflow is the error reported if the event channel overflows with OverflowError policy.
+var ErrOverflow = fdevents.ErrOverflow
+
+// ErrClosed is the error returned by WaitEdge and WaitValue if the event channel
+// is closed by Close.
+var ErrClosed = fdevents.ErrClosed
+
 // Events returns a channel from which the occurrence time of GPIO events can be read.
 // The GPIO events of this pin will be sent to the returned channel, and the channel is closed when l is closed.
 //
-// Package gpiosysfs will not block sending to the channel: it only keeps the lastest
-// value in the channel.
+// Unless configured by WithEventBuffer, package gpiosysfs will not block sending to
+// the channel: it only keeps the lastest value in the channel.
 func (pin *PinWithEvent) Events() <-chan *Event {
 	return pin.events.Events()
 }
 
+// Overwritten returns the number of events discarded because the channel
+// returned by Events was full.
+func (pin *PinWithEvent) Overwritten() uint64 {
+	return pin.events.Overwritten()
+}
+
+// Err returns the error that caused the channel returned by Events to be closed,
+// such as ErrOverflow or an error reading the value. It returns nil if the channel
+// is open or closed by Close.
+func (pin *PinWithEvent) Err() error {
+	return pin.events.Err()
+}
+
+// WaitEdge waits for the next event of edge and returns it. Parameter edge is Rising,
+// Falling or Both. Events already in the channel returned by Events are taken
+// into account, and the events not of edge are discarded.
+// If ctx is done first, WaitEdge returns ctx.Err().
+// If the channel is closed first, WaitEdge returns the error returned by Err,
+// or ErrClosed if pin is closed.
+func (pin *PinWithEvent) WaitEdge(ctx context.Context, edge Edge) (*Event, error) {
+	if edge != Rising && edge != Falling && edge != Both {
+		return nil, fmt.Errorf("failed to wait edge of pin #%v: invalid edge %q", pin.n, edge)
+	}
+	return pin.events.Wait(ctx, func(e *Event) bool {
+		if e.RisingEdge {
+			return edge != Falling
+		}
+		return edge != Rising
+	})
+}
+
+// WaitValue waits until the value of the pin becomes value, 1 (high) or 0 (low),
+// and returns the event of the edge changing the value. If the pin already has
+// the value, WaitValue returns immediately an Event made from reading the value:
+// RisingEdge is whether value is 1, and Time is the time of reading.
+// The events occurred before reading the current value are discarded, because
+// the value is already the result of them.
+// The edge must be selected by SetEdge, or WaitValue waits until ctx is done.
+// See WaitEdge for the errors returned.
+func (pin *PinWithEvent) WaitValue(ctx context.Context, value byte) (*Event, error) {
+	start := time.Now()
+	current, err := pin.Value()
+	if err != nil {
+		return nil, err
+	}
+	if value != 0 {
+		value = 1
+	}
+	if current == value {
+		return &Event{RisingEdge: value == 1, Time: time.Now()}, nil
+	}
+	// The times of events are read by time.Now too, so they are comparable with start.
+	return pin.events.Wait(ctx, func(e *Event) bool {
+		return e.RisingEdge == (value == 1) && !e.Time.Before(start)
+	})
+}
+
 func writeExisting(path string, content string) (err error) {
 	f, err := os.OpenFile(path, os.O_WRONLY, 0)
 	if err != nil {
@@ -0,0 +1,304 @@
+package gpiosysfs
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/mkch/asserting"
+	"golang.org/x/sys/unix"
+)
+
+// fakeSysfs is a fake GPIO sysfs tree in a temporary directory.
+// The export and unexport files are FIFOs, read by goroutines creating
+// and removing the pin directories. Unlike the kernel, exporting and
+// unexporting are done after the writes return, see exported and unexported.
+// The attributes of pins are regular files, see attr.
+type fakeSysfs struct {
+	root     string
+	oldRoot  string
+	stop     chan struct{} // Closed to stop the emulation.
+	emulated []chan struct{}
+	closers  []io.Closer // Closed by Close, see OpenPinWithEvents.
+}
+
+// newFakeSysfs creates a fake sysfs tree with a controller of 32 pins at base 0,
+// and a controller of 8 pins at base 32, and sets Root to it.
+func newFakeSysfs(t TB) *fakeSysfs {
+	root, err := ioutil.TempDir("", "gpiosysfs")
+	t.AssertNoError(err)
+	var fs = &fakeSysfs{root: root, oldRoot: Root, stop: make(chan struct{})}
+	fs.writeChip(t, "gpiochip0", "0", "pinctrl-bcm2835", "32")
+	fs.writeChip(t, "gpiochip32", "32", "expander", "8")
+	fs.emulate(t, "export", func(dir string) {
+		// Create the pin directory atomically, like the kernel does.
+		tmp, err := ioutil.TempDir(root, "export")
+		if err != nil {
+			return
+		}
+		for file, content := range map[string]string{"direction": "in", "value": "0", "edge": "none", "active_low": "0"} {
+			ioutil.WriteFile(filepath.Join(tmp, file), []byte(content+"\n"), 0644)
+		}
+		if os.Rename(tmp, dir) != nil {
+			os.RemoveAll(tmp)
+		}
+	})
+	fs.emulate(t, "unexport", func(dir string) {
+		os.RemoveAll(dir)
+	})
+	Root = root
+	return fs
+}
+
+// writeChip writes a gpiochipN directory.
+func (fs *fakeSysfs) writeChip(t TB, name, base, label, ngpio string) {
+	dir := filepath.Join(fs.root, name)
+	t.AssertNoError(os.Mkdir(dir, 0755))
+	for file, content := range map[string]string{"base": base, "label": label, "ngpio": ngpio} {
+		t.AssertNoError(ioutil.WriteFile(filepath.Join(dir, file), []byte(content+"\n"), 0444))
+	}
+}
+
+// emulate creates the FIFO file, and calls handle with the pin directory
+// of each number written to it.
+func (fs *fakeSysfs) emulate(t TB, file string, handle func(dir string)) {
+	path := filepath.Join(fs.root, file)
+	t.AssertNoError(unix.Mkfifo(path, 0600))
+	done := make(chan struct{})
+	fs.emulated = append(fs.emulated, done)
+	go func() {
+		defer close(done)
+		for {
+			// Blocks until written and closed by the writers.
+			buf, err := ioutil.ReadFile(path)
+			if err != nil {
+				return
+			}
+			if n, err := strconv.Atoi(trimNewlines(buf)); err == nil {
+				handle(filepath.Join(fs.root, "gpio"+strconv.Itoa(n)))
+			}
+			select {
+			case <-fs.stop:
+				return
+			default:
+			}
+		}
+	}()
+}
+
+// Close stops the emulation, removes the tree and restores Root.
+func (fs *fakeSysfs) Close() {
+	close(fs.stop)
+	for i, file := range []string{"export", "unexport"} {
+		fs.wakeUp(filepath.Join(fs.root, file), fs.emulated[i])
+	}
+	for _, closer := range fs.closers {
+		closer.Close()
+	}
+	os.RemoveAll(fs.root)
+	Root = fs.oldRoot
+}
+
+// wakeUp opens and closes the FIFO file without writing until the reader
+// is done. The reader may have stopped already, or be about to open the file
+// again, so the file is opened without blocking.
+func (fs *fakeSysfs) wakeUp(path string, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if f, err := os.OpenFile(path, os.O_WRONLY|unix.O_NONBLOCK, 0); err == nil {
+			f.Close()
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// read returns the content of the file of pin #n.
+func (fs *fakeSysfs) read(t TB, n int, file string) string {
+	buf, err := ioutil.ReadFile(filepath.Join(fs.root, "gpio"+strconv.Itoa(n), file))
+	t.AssertNoError(err)
+	return trimNewlines(buf)
+}
+
+// attrValues are the values that can be written to the attributes of pins,
+// grouped by the value shown after writing them, which is the first of each group.
+var attrValues = map[string][][]string{
+	"direction":  {{"in"}, {"out", "high", "low"}},
+	"edge":       {{"none"}, {"rising"}, {"falling"}, {"both"}},
+	"value":      {{"0"}, {"1"}},
+	"active_low": {{"0"}, {"1"}},
+}
+
+// attr returns the value last written to the attribute file of pin #n, and
+// updates the file to show the value like the kernel does. The writes of Pin
+// don't truncate regular files, so a shorter value leaves the end of the
+// previous one, which is removed here.
+func (fs *fakeSysfs) attr(t TB, n int, file string) string {
+	content := fs.read(t, n, file)
+	for _, values := range attrValues[file] {
+		for _, value := range values {
+			if strings.HasPrefix(content, value) {
+				path := filepath.Join(fs.root, "gpio"+strconv.Itoa(n), file)
+				t.AssertNoError(ioutil.WriteFile(path, []byte(values[0]+"\n"), 0644))
+				return value
+			}
+		}
+	}
+	t.Fatalf("invalid %v of pin #%v: %q", file, n, content)
+	return ""
+}
+
+// exported waits for pin #n to be exported, and returns whether it is
+// exported in time.
+func (fs *fakeSysfs) exported(n int) bool {
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		if fs.isExported(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// unexported waits for pin #n to be unexported, and returns whether it is
+// unexported in time.
+func (fs *fakeSysfs) unexported(n int) bool {
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		if !fs.isExported(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// isExported returns whether pin #n is exported.
+func (fs *fakeSysfs) isExported(n int) bool {
+	_, err := os.Stat(filepath.Join(fs.root, "gpio"+strconv.Itoa(n)))
+	return err == nil
+}
+
+func TestControllers(t1 *testing.T) {
+	t := NewTB(t1)
+	fs := newFakeSysfs(t)
+	defer fs.Close()
+
+	chips, err := Controllers()
+	t.AssertNoError(err)
+	sort.Slice(chips, func(i, j int) bool { return chips[i].Base < chips[j].Base })
+	t.AssertEqualSlice(chips, []Chip{
+		{Base: 0, Label: "pinctrl-bcm2835", Ngpio: 32},
+		{Base: 32, Label: "expander", Ngpio: 8},
+	})
+
+	chip, err := Controller(32)
+	t.AssertNoError(err)
+	t.AssertEqual(chip, Chip{Base: 32, Label: "expander", Ngpio: 8})
+	_, err = Controller(1)
+	t.Assert(err, NotEquals(nil))
+}
+
+func TestOpenPin(t1 *testing.T) {
+	t := NewTB(t1)
+	fs := newFakeSysfs(t)
+	defer fs.Close()
+
+	pin, err := OpenPin(17)
+	t.AssertNoError(err)
+	t.AssertTrue(fs.exported(17))
+
+	dir, err := pin.Direction()
+	t.AssertNoError(err)
+	t.AssertEqual(dir, In)
+	t.AssertNoError(pin.SetOutput(1))
+	t.AssertEqual(fs.attr(t, 17, "direction"), "high")
+	t.AssertNoError(pin.SetDirection(Out))
+	t.AssertEqual(fs.attr(t, 17, "direction"), "out")
+	dir, err = pin.Direction()
+	t.AssertNoError(err)
+	t.AssertEqual(dir, Direction(Out))
+	t.AssertNoError(pin.SetInput())
+	t.AssertEqual(fs.attr(t, 17, "direction"), "in")
+	dir, err = pin.Direction()
+	t.AssertNoError(err)
+	t.AssertEqual(dir, In)
+
+	edge, err := pin.Edge()
+	t.AssertNoError(err)
+	t.AssertEqual(edge, None)
+	t.AssertNoError(pin.SetEdge(Falling))
+	t.AssertEqual(fs.attr(t, 17, "edge"), "falling")
+	t.AssertNoError(pin.SetEdge(Both))
+	t.AssertEqual(fs.attr(t, 17, "edge"), "both")
+	edge, err = pin.Edge()
+	t.AssertNoError(err)
+	t.AssertEqual(edge, Edge(Both))
+
+	value, err := pin.Value()
+	t.AssertNoError(err)
+	t.AssertEqual(value, byte(0))
+	t.AssertNoError(ioutil.WriteFile(filepath.Join(fs.root, "gpio17", "value"), []byte("1\n"), 0644))
+	value, err = pin.Value()
+	t.AssertNoError(err)
+	t.AssertEqual(value, byte(1))
+	t.AssertNoError(pin.SetValue(0))
+	t.AssertEqual(fs.attr(t, 17, "value"), "0")
+
+	activeLow, err := pin.ActiveLow()
+	t.AssertNoError(err)
+	t.AssertEqual(activeLow, false)
+	t.AssertNoError(pin.SetActiveLow(true))
+	activeLow, err = pin.ActiveLow()
+	t.AssertNoError(err)
+	t.AssertEqual(activeLow, true)
+	t.AssertEqual(fs.attr(t, 17, "active_low"), "1")
+
+	t.AssertNoError(pin.Close())
+	t.AssertTrue(fs.unexported(17))
+}
+
+func TestOpenExportedPin(t1 *testing.T) {
+	t := NewTB(t1)
+	fs := newFakeSysfs(t)
+	defer fs.Close()
+
+	// Exported by someone else.
+	t.AssertNoError(writeExisting(filepath.Join(fs.root, "export"), "5"))
+	t.AssertTrue(fs.exported(5))
+	pin, err := OpenPin(5)
+	t.AssertNoError(err)
+	t.AssertNoError(pin.SetValue(1))
+	t.AssertEqual(fs.attr(t, 5, "value"), "1")
+	t.AssertNoError(pin.Close())
+	t.AssertTrue(fs.unexported(5))
+
+	// Not a directory.
+	t.AssertNoError(ioutil.WriteFile(filepath.Join(fs.root, "gpio6"), nil, 0644))
+	_, err = OpenPin(6)
+	t.Assert(err, NotEquals(nil))
+}
+
+func TestOpenPinError(t1 *testing.T) {
+	t := NewTB(t1)
+	root, err := ioutil.TempDir("", "gpiosysfs")
+	t.AssertNoError(err)
+	defer os.RemoveAll(root)
+	defer func(oldRoot string) { Root = oldRoot }(Root)
+	Root = root
+
+	// No export file.
+	_, err = OpenPin(1)
+	t.Assert(err, NotEquals(nil))
+	_, err = Controllers()
+	t.AssertNoError(err)
+	Root = filepath.Join(root, "not-exist")
+	_, err = Controllers()
+	t.Assert(err, NotEquals(nil))
+}
@@ -0,0 +1,385 @@
+package gpiotest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/mkch/gpio"
+	"github.com/mkch/gpio/internal/sim"
+	"github.com/mkch/gpio/internal/sys"
+	"golang.org/x/sys/unix"
+)
+
+// Chip is a simulated GPIO chip.
+// It is safe to call the methods of Chip concurrently.
+type Chip struct {
+	name  string
+	label string
+
+	lock             sync.Mutex
+	closed           bool
+	permissionDenied bool
+	lines            []line
+	// The opened chip fds, keyed by the read end of the pipe.
+	chipFds map[int]*chipFd
+	// The line request fds, keyed by the read end of the pipe.
+	requests map[int]*request
+	nets     []*Net
+}
+
+// line is the state of a simulated line.
+type line struct {
+	name string
+	// Whether the line is used outside of the simulation, see SetBusy.
+	busy         bool
+	busyConsumer string
+	// The request holding the line, nil if not requested.
+	req *request
+	// The uAPI v2 line flags, without GPIO_V2_LINE_FLAG_USED.
+	flags      uint64
+	debounceUs uint32
+	// The physical level set by the request if the line is output.
+	output byte
+	// The physical level driven by Drive if driven is true.
+	driven bool
+	drive  byte
+	// The net the line is connected to, nil if not connected.
+	net *Net
+}
+
+// chipFd is an opened chip fd. The fd is the read end of a pipe,
+// to which the line info changes are written.
+type chipFd struct {
+	w       int // The write end of the pipe, -1 if closed.
+	watched map[uint32]bool
+}
+
+// request is a line request fd. The fd is the read end of a pipe,
+// to which the edge events are written.
+type request struct {
+	w         int // The write end of the pipe, -1 if closed.
+	consumer  string
+	offsets   []uint32
+	seqno     uint32
+	lineSeqno []uint32
+}
+
+// now as a timestamp means the current time of the event clock.
+const now time.Duration = -1
+
+// NewChip creates a simulated GPIO chip with numLines lines.
+// Name is the name of the chip, and label is the label of the chip, may be empty.
+// The lines are inputs without bias, so they read 0 until driven.
+//
+// The simulated chip is not a chip device, so it is not listed by
+// gpio.ChipDevices, and is not found by gpio.ChipPath, gpio.OpenChip or
+// gpio.FindLine. Open it with Open.
+func NewChip(name, label string, numLines int) (*Chip, error) {
+	if name == "" {
+		return nil, errors.New("create simulated chip failed: empty name")
+	}
+	if numLines <= 0 {
+		return nil, fmt.Errorf("create simulated chip %v failed: invalid number of lines %v", name, numLines)
+	}
+	var c = &Chip{
+		name:     name,
+		label:    label,
+		lines:    make([]line, numLines),
+		chipFds:  make(map[int]*chipFd),
+		requests: make(map[int]*request),
+	}
+	for i := range c.lines {
+		c.lines[i].flags = sys.GPIO_V2_LINE_FLAG_INPUT
+	}
+	return c, nil
+}
+
+// Name returns the name of the chip.
+func (c *Chip) Name() string {
+	return c.name
+}
+
+// Open opens the chip as a gpio.Chip.
+func (c *Chip) Open() (*gpio.Chip, error) {
+	chip, err := sim.OpenChip(c.name, (*device)(c))
+	if err != nil {
+		return nil, err
+	}
+	return chip.(*gpio.Chip), nil
+}
+
+// Close removes the chip, as if it were unplugged.
+// The chip can't be opened any more, the operations on the opened chips and
+// lines fail with ENODEV, and the channels of events and line info changes are closed.
+func (c *Chip) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return fmt.Errorf("close simulated chip %v failed: already closed", c.name)
+	}
+	c.closed = true
+	for _, cfd := range c.chipFds {
+		closeWriteEnd(&cfd.w)
+	}
+	for _, r := range c.requests {
+		closeWriteEnd(&r.w)
+	}
+	for _, n := range c.nets {
+		n.stop()
+	}
+	return nil
+}
+
+// closeWriteEnd closes the write end of a pipe, so the reader gets EOF.
+func closeWriteEnd(w *int) {
+	if *w >= 0 {
+		unix.Close(*w)
+		*w = -1
+	}
+}
+
+// checkOffset returns an error if offset is out of range.
+func (c *Chip) checkOffset(op string, offset uint32) error {
+	if offset >= uint32(len(c.lines)) {
+		return fmt.Errorf("%v line %v of simulated chip %v failed: offset out of range [0, %v)", op, offset, c.name, len(c.lines))
+	}
+	return nil
+}
+
+// SetLineName sets the name of a line, which is reported by gpio.Chip.LineInfo
+// and can be found by gpio.FindLine.
+func (c *Chip) SetLineName(offset uint32, name string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.checkOffset("set name of", offset); err != nil {
+		return err
+	}
+	c.lines[offset].name = name
+	return nil
+}
+
+// Drive drives a line to level externally, 0 (low) or 1 (high), anything
+// else than 0 is interpreted as 1. Edge events are generated if the line is
+// requested for the edge. A line requested as output keeps its output level,
+// unless it is open-drain or open-source and not driving the line.
+// Driving a connected line drives the net, see Connect.
+func (c *Chip) Drive(offset uint32, level byte) error {
+	return c.DriveAt(offset, level, now)
+}
+
+// DriveAt is like Drive, but the edge events are timestamped with timestamp,
+// which is the Event.Timestamp received. Drive uses the current time of the
+// event clock of the line.
+func (c *Chip) DriveAt(offset uint32, level byte, timestamp time.Duration) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.checkOffset("drive", offset); err != nil {
+		return err
+	}
+	c.update(timestamp, func() {
+		c.lines[offset].driven = true
+		c.lines[offset].drive = bit(level)
+	})
+	return nil
+}
+
+// Float stops driving a line by Drive. The level of an undriven input line
+// is decided by its bias: 1 with PullUp, and 0 otherwise.
+func (c *Chip) Float(offset uint32) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.checkOffset("float", offset); err != nil {
+		return err
+	}
+	c.update(now, func() {
+		c.lines[offset].driven = false
+	})
+	return nil
+}
+
+// Level returns the physical level of a line, regardless of ActiveLow.
+// It is the output level if the line is requested as output, the level of
+// the net if connected, and is decided by Drive and the bias otherwise.
+func (c *Chip) Level(offset uint32) (byte, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.checkOffset("get level of", offset); err != nil {
+		return 0, err
+	}
+	return c.level(offset), nil
+}
+
+// SetBusy marks a line as used outside of the process by consumer, such as
+// the kernel or another process. Requesting the line fails with gpio.ErrLineBusy.
+func (c *Chip) SetBusy(offset uint32, consumer string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.checkOffset("set busy", offset); err != nil {
+		return err
+	}
+	c.lines[offset].busy = true
+	c.lines[offset].busyConsumer = consumer
+	return nil
+}
+
+// ClearBusy clears the mark set by SetBusy.
+func (c *Chip) ClearBusy(offset uint32) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.checkOffset("clear busy", offset); err != nil {
+		return err
+	}
+	c.lines[offset].busy = false
+	c.lines[offset].busyConsumer = ""
+	return nil
+}
+
+// SetPermissionDenied sets whether opening the chip fails with gpio.ErrPermission,
+// as if the chip device were not accessible. The opened chips are not affected.
+func (c *Chip) SetPermissionDenied(denied bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.permissionDenied = denied
+}
+
+// bit converts level to 0 or 1.
+func bit(level byte) byte {
+	if level != 0 {
+		return 1
+	}
+	return 0
+}
+
+// level returns the physical level of the line at offset.
+func (c *Chip) level(offset uint32) byte {
+	l := &c.lines[offset]
+	if level, ok := l.outputLevel(); ok {
+		return level
+	}
+	if l.net != nil {
+		return l.net.level
+	}
+	if l.driven {
+		return l.drive
+	}
+	if l.flags&sys.GPIO_V2_LINE_FLAG_BIAS_PULL_UP != 0 {
+		return 1
+	}
+	return 0
+}
+
+// outputLevel returns the level driven by l if it is requested as output.
+// Ok is false if l is not output, or is high impedance.
+func (l *line) outputLevel() (level byte, ok bool) {
+	if l.req == nil || l.flags&sys.GPIO_V2_LINE_FLAG_OUTPUT == 0 {
+		return
+	}
+	switch {
+	case l.flags&sys.GPIO_V2_LINE_FLAG_OPEN_DRAIN != 0 && l.output == 1:
+		return // High impedance.
+	case l.flags&sys.GPIO_V2_LINE_FLAG_OPEN_SOURCE != 0 && l.output == 0:
+		return // High impedance.
+	}
+	return l.output, true
+}
+
+// update calls mutate to change the state of the lines, and generates the edge
+// events of the level changes, timestamped with timestamp.
+func (c *Chip) update(timestamp time.Duration, mutate func()) {
+	var levels = make([]byte, len(c.lines))
+	var reqs = make([]*request, len(c.lines))
+	for i := range c.lines {
+		levels[i] = c.level(uint32(i))
+		reqs[i] = c.lines[i].req
+	}
+	mutate()
+	c.propagate()
+	for i := range c.lines {
+		// Requesting and releasing a line do not generate events.
+		if c.lines[i].req == nil || c.lines[i].req != reqs[i] {
+			continue
+		}
+		if level := c.level(uint32(i)); level != levels[i] {
+			c.edge(uint32(i), level, timestamp)
+		}
+	}
+}
+
+// edge writes an edge event to the request of the line at offset, if the
+// line is requested for the edge. Level is the new physical level.
+func (c *Chip) edge(offset uint32, level byte, timestamp time.Duration) {
+	l := &c.lines[offset]
+	var event = sys.GPIOV2LineEvent{Offset: offset}
+	if level^activeLow(l.flags) == 1 {
+		if l.flags&sys.GPIO_V2_LINE_FLAG_EDGE_RISING == 0 {
+			return
+		}
+		event.ID = sys.GPIO_V2_LINE_EVENT_RISING_EDGE
+	} else {
+		if l.flags&sys.GPIO_V2_LINE_FLAG_EDGE_FALLING == 0 {
+			return
+		}
+		event.ID = sys.GPIO_V2_LINE_EVENT_FALLING_EDGE
+	}
+	if timestamp == now {
+		var clock = unix.CLOCK_MONOTONIC
+		if l.flags&sys.GPIO_V2_LINE_FLAG_EVENT_CLOCK_REALTIME != 0 {
+			clock = unix.CLOCK_REALTIME
+		}
+		timestamp = clockNow(clock)
+	}
+	event.TimestampNs = uint64(timestamp)
+	r := l.req
+	r.seqno++
+	event.Seqno = r.seqno
+	for i, o := range r.offsets {
+		if o == offset {
+			r.lineSeqno[i]++
+			event.LineSeqno = r.lineSeqno[i]
+		}
+	}
+	writeStruct(r.w, unsafe.Pointer(&event), unsafe.Sizeof(event))
+}
+
+// activeLow returns 1 if flags has GPIO_V2_LINE_FLAG_ACTIVE_LOW, and 0 otherwise.
+func activeLow(flags uint64) byte {
+	if flags&sys.GPIO_V2_LINE_FLAG_ACTIVE_LOW != 0 {
+		return 1
+	}
+	return 0
+}
+
+// clockNow returns the current time of clock.
+func clockNow(clock int) time.Duration {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(int32(clock), &ts); err != nil {
+		panic(err) // Never fails with valid clocks.
+	}
+	return time.Duration(ts.Nano())
+}
+
+// writeStruct writes size bytes at p to fd. The data is discarded if fd is closed
+// or the pipe is full, like the kernel does when its buffer is full.
+func writeStruct(fd int, p unsafe.Pointer, size uintptr) {
+	if fd < 0 {
+		return
+	}
+	unix.Write(fd, (*[1 << 16]byte)(p)[:size:size])
+}
+
+// newPipe creates a pipe for a fd, and returns the read end and the non-blocking write end.
+func newPipe() (r, w int, err error) {
+	var p [2]int
+	if err = unix.Pipe2(p[:], unix.O_CLOEXEC); err != nil {
+		return
+	}
+	if err = unix.SetNonblock(p[1], true); err != nil {
+		unix.Close(p[0])
+		unix.Close(p[1])
+		return
+	}
+	return p[0], p[1], nil
+}
@@ -0,0 +1,358 @@
+package gpiotest_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/mkch/asserting"
+	"github.com/mkch/gpio"
+	"github.com/mkch/gpio/gpiotest"
+	"golang.org/x/sys/unix"
+)
+
+// newChip creates a simulated chip, and opens it.
+func newChip(t TB, name string, numLines int) (*gpiotest.Chip, *gpio.Chip) {
+	sim, err := gpiotest.NewChip(name, name+"-label", numLines)
+	t.AssertNoError(err)
+	chip, err := sim.Open()
+	t.AssertNoError(err)
+	return sim, chip
+}
+
+func TestChipInfo(t1 *testing.T) {
+	t := NewTB(t1)
+	sim, chip := newChip(t, "sim-info", 4)
+	defer sim.Close()
+	defer chip.Close()
+	t.AssertNoError(sim.SetLineName(2, "LED"))
+
+	info, err := chip.Info()
+	t.AssertNoError(err)
+	t.AssertEqual(info, gpio.ChipInfo{Name: "sim-info", Label: "sim-info-label", NumLines: 4})
+	lineInfo, err := chip.LineInfo(2)
+	t.AssertNoError(err)
+	t.AssertEqual(lineInfo.Name, "LED")
+	t.AssertEqual(lineInfo.Kernel(), false)
+
+	lineInfo, err = chip.LineInfo(4)
+	t.AssertTrue(errors.Is(err, gpio.ErrInvalidOffset))
+	t.AssertEqual(lineInfo, gpio.LineInfo{})
+}
+
+func TestNewChipName(t1 *testing.T) {
+	t := NewTB(t1)
+	root, err := ioutil.TempDir("", "gpio-dev")
+	t.AssertNoError(err)
+	defer os.RemoveAll(root)
+	defer func(oldRoot string) { gpio.DeviceRoot = oldRoot }(gpio.DeviceRoot)
+	gpio.DeviceRoot = root
+
+	_, err = gpiotest.NewChip("", "", 1)
+	t.Assert(err, NotEquals(nil))
+
+	// Simulated chips never collide with the chip devices.
+	sim, err := gpiotest.NewChip("gpiochip0", "sim-name-label", 1)
+	t.AssertNoError(err)
+	defer sim.Close()
+	t.AssertNoError(sim.SetLineName(0, "sim-name-line"))
+	t.AssertEqual(len(gpio.ChipDevices()), 0)
+	_, err = gpio.OpenChip("gpiochip0")
+	t.AssertTrue(errors.Is(err, os.ErrNotExist))
+	_, err = gpio.ChipPath("sim-name-label")
+	t.AssertTrue(errors.Is(err, os.ErrNotExist))
+	_, _, err = gpio.FindLine("sim-name-line")
+	var notFound *gpio.LineNotFoundError
+	t.AssertTrue(errors.As(err, &notFound))
+
+	chip, err := sim.Open()
+	t.AssertNoError(err)
+	defer chip.Close()
+	info, err := chip.Info()
+	t.AssertNoError(err)
+	t.AssertEqual(info.Name, "gpiochip0")
+	// Two simulated chips can have the same name.
+	sim2, err := gpiotest.NewChip("gpiochip0", "", 1)
+	t.AssertNoError(err)
+	t.AssertNoError(sim2.Close())
+}
+
+func TestOutput(t1 *testing.T) {
+	t := NewTB(t1)
+	sim, chip := newChip(t, "sim-output", 4)
+	defer sim.Close()
+	defer chip.Close()
+
+	lines, err := chip.RequestLines([]uint32{1, 3}, gpio.WithOutput(1, 0), gpio.ForOffset(3, gpio.WithActiveLow(true)))
+	t.AssertNoError(err)
+	defer lines.Close()
+	level, err := sim.Level(1)
+	t.AssertNoError(err)
+	t.AssertEqual(level, byte(1))
+	level, err = sim.Level(3)
+	t.AssertNoError(err)
+	t.AssertEqual(level, byte(1))
+
+	t.AssertNoError(lines.SetValues([]byte{0, 1}))
+	level, _ = sim.Level(1)
+	t.AssertEqual(level, byte(0))
+	level, _ = sim.Level(3)
+	t.AssertEqual(level, byte(0))
+	values, err := lines.Values()
+	t.AssertNoError(err)
+	t.AssertEqualSlice(values, []byte{0, 1})
+
+	info, err := chip.LineInfo(3)
+	t.AssertNoError(err)
+	t.AssertTrue(info.Output())
+	t.AssertTrue(info.ActiveLow())
+
+	// Open-drain output 1 does not drive the line.
+	t.AssertNoError(lines.Reconfigure(gpio.Output|gpio.OpenDrain, []byte{1, 1}))
+	level, _ = sim.Level(1)
+	t.AssertEqual(level, byte(0))
+	err = lines.Reconfigure(gpio.Output, nil, gpio.WithConsumer("a"))
+	t.AssertEqual(err.Error(), "reconfigure GPIO lines failed: consumer can't be reconfigured")
+}
+
+func TestInput(t1 *testing.T) {
+	t := NewTB(t1)
+	sim, chip := newChip(t, "sim-input", 4)
+	defer sim.Close()
+	defer chip.Close()
+
+	line, err := chip.RequestLine(0, gpio.WithBias(gpio.PullUp))
+	t.AssertNoError(err)
+	defer line.Close()
+	value, err := line.Value()
+	t.AssertNoError(err)
+	t.AssertEqual(value, byte(1))
+	t.AssertNoError(sim.Drive(0, 0))
+	value, _ = line.Value()
+	t.AssertEqual(value, byte(0))
+	t.AssertNoError(sim.Float(0))
+	value, _ = line.Value()
+	t.AssertEqual(value, byte(1))
+
+	t.AssertNoError(line.SetConfig(gpio.Input|gpio.ActiveLow|gpio.PullUp, 0))
+	value, _ = line.Value()
+	t.AssertEqual(value, byte(0))
+	// Writing an input line.
+	t.AssertTrue(errors.Is(line.SetValue(1), unix.EPERM))
+
+	t.Assert(sim.Drive(4, 1), NotEquals(nil))
+}
+
+func TestEvents(t1 *testing.T) {
+	t := NewTB(t1)
+	sim, chip := newChip(t, "sim-events", 4)
+	defer sim.Close()
+	defer chip.Close()
+
+	lines, err := chip.RequestLinesWithEvents([]uint32{1, 2}, gpio.ForOffset(2, gpio.WithEdges(gpio.FallingEdge)))
+	t.AssertNoError(err)
+	defer lines.Close()
+
+	t.AssertNoError(sim.DriveAt(1, 1, 100*time.Millisecond))
+	t.AssertNoError(sim.DriveAt(2, 1, 200*time.Millisecond)) // Rising edge, not requested.
+	t.AssertNoError(sim.DriveAt(2, 0, 300*time.Millisecond))
+	t.AssertNoError(sim.Drive(1, 0))
+
+	var expected = []gpio.Event{
+		{RisingEdge: true, Timestamp: 100 * time.Millisecond, Seqno: 1, LineSeqno: 1, Offset: 1},
+		{RisingEdge: false, Timestamp: 300 * time.Millisecond, Seqno: 2, LineSeqno: 1, Offset: 2},
+		{RisingEdge: false, Seqno: 3, LineSeqno: 2, Offset: 1},
+	}
+	for _, e := range expected {
+		select {
+		case event := <-lines.Events():
+			if e.Timestamp == 0 {
+				t.AssertTrue(event.Timestamp > 0)
+				e.Timestamp = event.Timestamp
+			}
+			e.Time = event.Time
+			t.AssertEqual(*event, e)
+		case <-time.After(time.Second):
+			t1.Fatal("no event")
+		}
+	}
+}
+
+func TestRequestError(t1 *testing.T) {
+	t := NewTB(t1)
+	sim, chip := newChip(t, "sim-request-error", 4)
+	defer sim.Close()
+	defer chip.Close()
+
+	var e *gpio.Error
+	_, err := chip.RequestLinesWithEvents(nil)
+	t.AssertTrue(errors.As(err, &e))
+	t.AssertEqual(e.Op, "open GPIO lines")
+	_, err = chip.RequestLinesWithEvents([]uint32{4})
+	t.AssertTrue(errors.Is(err, gpio.ErrInvalidOffset))
+}
+
+func TestWaitEdge(t1 *testing.T) {
+	t := NewTB(t1)
+	sim, chip := newChip(t, "sim-wait", 1)
+	defer sim.Close()
+	defer chip.Close()
+
+	line, err := chip.RequestLineWithEvents(0, gpio.WithActiveLow(true))
+	t.AssertNoError(err)
+	defer line.Close()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		sim.Drive(0, 1)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	// Physical rising edge is logical falling edge.
+	event, err := line.WaitEdge(ctx, gpio.FallingEdge)
+	t.AssertNoError(err)
+	t.AssertEqual(event.RisingEdge, false)
+}
+
+func TestWaitValue(t1 *testing.T) {
+	t := NewTB(t1)
+	sim, chip := newChip(t, "sim-wait-value", 2)
+	defer sim.Close()
+	defer chip.Close()
+
+	for offset, clock := range []gpio.EventClock{gpio.EventClockMonotonic, gpio.EventClockRealtime} {
+		line, err := chip.RequestLineWithEvents(uint32(offset),
+			gpio.WithEventBuffer(16, gpio.OverflowDropOldest), gpio.WithEventClock(clock))
+		t.AssertNoError(err)
+		defer line.Close()
+		// Queued rising and falling edges, which are already reflected by the value.
+		t.AssertNoError(sim.Drive(uint32(offset), 1))
+		t.AssertNoError(sim.Drive(uint32(offset), 0))
+		for start := time.Now(); len(line.Events()) < 2; time.Sleep(time.Millisecond) {
+			if time.Since(start) > time.Second {
+				t.Fatal("events not queued")
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err = line.WaitValue(ctx, 1)
+		t.AssertTrue(errors.Is(err, context.DeadlineExceeded))
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			sim.Drive(uint32(offset), 1)
+		}()
+		ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		event, err := line.WaitValue(ctx, 1)
+		t.AssertNoError(err)
+		t.AssertEqual(event.RisingEdge, true)
+		t.Assert(event.Timestamp, NotEquals(time.Duration(0)))
+		value, err := line.Value()
+		t.AssertNoError(err)
+		t.AssertEqual(value, byte(1))
+
+		// Already the value.
+		event, err = line.WaitValue(context.Background(), 1)
+		t.AssertNoError(err)
+		t.AssertEqual(*event, gpio.Event{RisingEdge: true, Time: event.Time, Offset: uint32(offset)})
+		t.AssertTrue(!event.Time.IsZero())
+	}
+}
+
+func TestBusy(t1 *testing.T) {
+	t := NewTB(t1)
+	sim, chip := newChip(t, "sim-busy", 4)
+	defer sim.Close()
+	defer chip.Close()
+
+	t.AssertNoError(sim.SetBusy(2, "kernel-driver"))
+	_, err := chip.RequestLines([]uint32{1, 2})
+	t.AssertTrue(errors.Is(err, gpio.ErrLineBusy))
+	var e *gpio.Error
+	t.AssertTrue(errors.As(err, &e))
+	t.AssertEqual(e.Consumer, "kernel-driver")
+	info, err := chip.LineInfo(2)
+	t.AssertNoError(err)
+	t.AssertTrue(info.Kernel())
+
+	line, err := chip.RequestLine(1, gpio.WithConsumer("relay"))
+	t.AssertNoError(err)
+	_, err = chip.RequestLine(1)
+	t.AssertTrue(errors.Is(err, gpio.ErrLineBusy))
+	t.AssertTrue(errors.As(err, &e))
+	t.AssertEqual(e.Consumer, "relay")
+	t.AssertNoError(line.Close())
+
+	t.AssertNoError(sim.ClearBusy(2))
+	lines, err := chip.RequestLines([]uint32{1, 2})
+	t.AssertNoError(err)
+	t.AssertNoError(lines.Close())
+
+	_, err = chip.RequestLine(4)
+	t.AssertTrue(errors.Is(err, gpio.ErrInvalidOffset))
+}
+
+func TestPermissionDenied(t1 *testing.T) {
+	t := NewTB(t1)
+	sim, err := gpiotest.NewChip("sim-perm", "", 1)
+	t.AssertNoError(err)
+	defer sim.Close()
+
+	sim.SetPermissionDenied(true)
+	_, err = sim.Open()
+	t.AssertTrue(errors.Is(err, gpio.ErrPermission))
+	sim.SetPermissionDenied(false)
+	chip, err := sim.Open()
+	t.AssertNoError(err)
+	t.AssertNoError(chip.Close())
+}
+
+func TestWatchLineInfo(t1 *testing.T) {
+	t := NewTB(t1)
+	sim, chip := newChip(t, "sim-watch", 2)
+	defer sim.Close()
+	defer chip.Close()
+
+	_, err := chip.WatchLineInfo(1)
+	t.AssertNoError(err)
+	line, err := chip.RequestLine(1, gpio.WithConsumer("watched"))
+	t.AssertNoError(err)
+	t.AssertNoError(line.SetOutput(1))
+	t.AssertNoError(line.Close())
+
+	for _, changeType := range []gpio.LineInfoChangeType{gpio.LineRequested, gpio.LineReconfigured, gpio.LineReleased} {
+		select {
+		case change := <-chip.LineInfoChanges():
+			t.AssertEqual(change.Type, changeType)
+			t.AssertEqual(change.Info.Offset, uint32(1))
+		case <-time.After(time.Second):
+			t1.Fatal("no line info change")
+		}
+	}
+	t.AssertNoError(chip.UnwatchLineInfo(1))
+}
+
+func TestRemove(t1 *testing.T) {
+	t := NewTB(t1)
+	sim, chip := newChip(t, "sim-remove", 1)
+	defer chip.Close()
+	line, err := chip.RequestLineWithEvents(0)
+	t.AssertNoError(err)
+	defer line.Close()
+
+	t.AssertNoError(sim.Close())
+	t.Assert(sim.Close(), NotEquals(nil))
+	_, err = sim.Open()
+	t.Assert(err, NotEquals(nil))
+	_, err = line.Value()
+	t.AssertTrue(errors.Is(err, unix.ENODEV))
+	select {
+	case _, ok := <-line.Events():
+		t.AssertEqual(ok, false)
+	case <-time.After(time.Second):
+		t1.Fatal("events not closed")
+	}
+}
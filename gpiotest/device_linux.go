@@ -0,0 +1,355 @@
+package gpiotest
+
+import (
+	"unsafe"
+
+	"github.com/mkch/gpio/internal/sys"
+	"golang.org/x/sys/unix"
+)
+
+// device is Chip as sim.Device, which handles the syscalls of package gpio
+// on the simulated fds. Only GPIO uAPI v2 is implemented.
+type device Chip
+
+// Open implements sim.Device.
+func (d *device) Open() (fd int, err error) {
+	c := (*Chip)(d)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return -1, unix.ENODEV
+	}
+	if c.permissionDenied {
+		return -1, unix.EACCES
+	}
+	r, w, err := newPipe()
+	if err != nil {
+		return -1, err
+	}
+	c.chipFds[r] = &chipFd{w: w, watched: make(map[uint32]bool)}
+	return r, nil
+}
+
+// Close implements sim.Device.
+func (d *device) Close(fd int) error {
+	c := (*Chip)(d)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if cfd, ok := c.chipFds[fd]; ok {
+		delete(c.chipFds, fd)
+		closeWriteEnd(&cfd.w)
+		return unix.Close(fd)
+	}
+	if r, ok := c.requests[fd]; ok {
+		delete(c.requests, fd)
+		c.release(r)
+		closeWriteEnd(&r.w)
+		return unix.Close(fd)
+	}
+	return unix.EBADF
+}
+
+// Ioctl implements sim.Device.
+func (d *device) Ioctl(fd int, request uintptr, arg unsafe.Pointer) error {
+	c := (*Chip)(d)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cfd, isChip := c.chipFds[fd]
+	r, isRequest := c.requests[fd]
+	switch {
+	case !isChip && !isRequest:
+		return unix.EBADF
+	case c.closed:
+		return unix.ENODEV
+	case isChip:
+		return c.chipIoctl(cfd, request, arg)
+	default:
+		return c.lineIoctl(r, request, arg)
+	}
+}
+
+// chipIoctl handles the ioctl request on a chip fd.
+func (c *Chip) chipIoctl(cfd *chipFd, request uintptr, arg unsafe.Pointer) error {
+	switch request {
+	case sys.GPIO_GET_CHIPINFO_IOCTL:
+		info := (*sys.GPIOChipInfo)(arg)
+		info.Name = sys.Char32(c.name)
+		info.Label = sys.Char32(c.label)
+		info.Lines = uint32(len(c.lines))
+		return nil
+	case sys.GPIO_V2_GET_LINEINFO_IOCTL, sys.GPIO_V2_GET_LINEINFO_WATCH_IOCTL:
+		info := (*sys.GPIOV2LineInfo)(arg)
+		offset := info.Offset
+		if offset >= uint32(len(c.lines)) {
+			return unix.EINVAL
+		}
+		if request == sys.GPIO_V2_GET_LINEINFO_WATCH_IOCTL {
+			if cfd.watched[offset] {
+				return unix.EBUSY
+			}
+			cfd.watched[offset] = true
+		}
+		c.lineInfo(offset, info)
+		return nil
+	case sys.GPIO_GET_LINEINFO_UNWATCH_IOCTL:
+		offset := *(*uint32)(arg)
+		if offset >= uint32(len(c.lines)) {
+			return unix.EINVAL
+		}
+		if !cfd.watched[offset] {
+			return unix.EBUSY
+		}
+		delete(cfd.watched, offset)
+		return nil
+	case sys.GPIO_V2_GET_LINE_IOCTL:
+		return c.requestLines((*sys.GPIOV2LineRequest)(arg))
+	default:
+		return unix.ENOTTY
+	}
+}
+
+// lineIoctl handles the ioctl request on a line request fd.
+func (c *Chip) lineIoctl(r *request, request uintptr, arg unsafe.Pointer) error {
+	switch request {
+	case sys.GPIO_V2_LINE_GET_VALUES_IOCTL:
+		values := (*sys.GPIOV2LineValues)(arg)
+		if values.Mask == 0 {
+			return unix.EINVAL
+		}
+		values.Bits = 0
+		for i, offset := range r.offsets {
+			if values.Mask&(1<<uint(i)) != 0 {
+				values.Bits |= uint64(c.level(offset)^activeLow(c.lines[offset].flags)) << uint(i)
+			}
+		}
+		return nil
+	case sys.GPIO_V2_LINE_SET_VALUES_IOCTL:
+		values := (*sys.GPIOV2LineValues)(arg)
+		if values.Mask == 0 {
+			return unix.EINVAL
+		}
+		for i, offset := range r.offsets {
+			if values.Mask&(1<<uint(i)) != 0 && c.lines[offset].flags&sys.GPIO_V2_LINE_FLAG_OUTPUT == 0 {
+				return unix.EPERM
+			}
+		}
+		c.update(now, func() {
+			for i, offset := range r.offsets {
+				if values.Mask&(1<<uint(i)) != 0 {
+					l := &c.lines[offset]
+					l.output = byte(values.Bits>>uint(i)&1) ^ activeLow(l.flags)
+				}
+			}
+		})
+		return nil
+	case sys.GPIO_V2_LINE_SET_CONFIG_IOCTL:
+		configs, err := c.lineConfigs(r.offsets, (*sys.GPIOV2LineConfig)(arg))
+		if err != nil {
+			return err
+		}
+		c.update(now, func() {
+			for i, offset := range r.offsets {
+				configs[i].apply(&c.lines[offset])
+			}
+		})
+		for _, offset := range r.offsets {
+			c.infoChanged(offset, sys.GPIO_V2_LINE_CHANGED_CONFIG)
+		}
+		return nil
+	default:
+		return unix.ENOTTY
+	}
+}
+
+// lineConfig is the configuration of a requested line.
+type lineConfig struct {
+	flags      uint64
+	value      byte // The logical output value.
+	debounceUs uint32
+}
+
+// apply applies config to l.
+func (config *lineConfig) apply(l *line) {
+	l.flags = config.flags
+	l.debounceUs = config.debounceUs
+	if config.flags&sys.GPIO_V2_LINE_FLAG_OUTPUT != 0 {
+		l.output = config.value ^ activeLow(config.flags)
+	}
+}
+
+// lineConfigs decodes and validates the configurations of the lines at offsets
+// from config.
+func (c *Chip) lineConfigs(offsets []uint32, config *sys.GPIOV2LineConfig) ([]lineConfig, error) {
+	if config.NumAttrs > sys.GPIO_V2_LINE_NUM_ATTRS_MAX {
+		return nil, unix.EINVAL
+	}
+	var configs = make([]lineConfig, len(offsets))
+	for i, offset := range offsets {
+		var mask = uint64(1) << uint(i)
+		var flagsFound, valueFound, debounceFound bool
+		configs[i].flags = config.Flags
+		// The first attribute of each kind applying to the line wins.
+		for j := uint32(0); j < config.NumAttrs; j++ {
+			attr := &config.Attrs[j]
+			if attr.Mask&mask == 0 {
+				continue
+			}
+			switch attr.Attr.ID {
+			case sys.GPIO_V2_LINE_ATTR_ID_FLAGS:
+				if !flagsFound {
+					configs[i].flags = attr.Attr.Flags()
+					flagsFound = true
+				}
+			case sys.GPIO_V2_LINE_ATTR_ID_OUTPUT_VALUES:
+				if !valueFound {
+					configs[i].value = byte(attr.Attr.Values() >> uint(i) & 1)
+					valueFound = true
+				}
+			case sys.GPIO_V2_LINE_ATTR_ID_DEBOUNCE:
+				if !debounceFound {
+					configs[i].debounceUs = attr.Attr.DebouncePeriodUs()
+					debounceFound = true
+				}
+			default:
+				return nil, unix.EINVAL
+			}
+		}
+		if !validFlags(configs[i].flags) {
+			return nil, unix.EINVAL
+		}
+		// Neither input nor output means the direction is left as is.
+		if configs[i].flags&(sys.GPIO_V2_LINE_FLAG_INPUT|sys.GPIO_V2_LINE_FLAG_OUTPUT) == 0 {
+			configs[i].flags |= c.lines[offset].flags & (sys.GPIO_V2_LINE_FLAG_INPUT | sys.GPIO_V2_LINE_FLAG_OUTPUT)
+		}
+	}
+	return configs, nil
+}
+
+// validFlags returns whether flags is a valid combination of line flags,
+// as validated by the kernel.
+func validFlags(flags uint64) bool {
+	const (
+		direction = sys.GPIO_V2_LINE_FLAG_INPUT | sys.GPIO_V2_LINE_FLAG_OUTPUT
+		edges     = sys.GPIO_V2_LINE_FLAG_EDGE_RISING | sys.GPIO_V2_LINE_FLAG_EDGE_FALLING
+		drives    = sys.GPIO_V2_LINE_FLAG_OPEN_DRAIN | sys.GPIO_V2_LINE_FLAG_OPEN_SOURCE
+		biases    = sys.GPIO_V2_LINE_FLAG_BIAS_PULL_UP | sys.GPIO_V2_LINE_FLAG_BIAS_PULL_DOWN | sys.GPIO_V2_LINE_FLAG_BIAS_DISABLED
+		clocks    = sys.GPIO_V2_LINE_FLAG_EVENT_CLOCK_REALTIME | sys.GPIO_V2_LINE_FLAG_EVENT_CLOCK_HTE
+		known     = sys.GPIO_V2_LINE_FLAG_ACTIVE_LOW | direction | edges | drives | biases | clocks
+	)
+	// More than one of the mutually exclusive flags.
+	multiple := func(f uint64) bool { return f&(f-1) != 0 }
+	switch {
+	case flags&^known != 0:
+		return false
+	case multiple(flags & direction), multiple(flags & drives), multiple(flags & biases), multiple(flags & clocks):
+		return false
+	case flags&edges != 0 && flags&sys.GPIO_V2_LINE_FLAG_INPUT == 0:
+		return false
+	case flags&drives != 0 && flags&sys.GPIO_V2_LINE_FLAG_OUTPUT == 0:
+		return false
+	case flags&biases != 0 && flags&direction == 0:
+		return false
+	}
+	return true
+}
+
+// requestLines handles GPIO_V2_GET_LINE_IOCTL.
+func (c *Chip) requestLines(req *sys.GPIOV2LineRequest) error {
+	if req.NumLines == 0 || req.NumLines > sys.GPIO_V2_LINES_MAX {
+		return unix.EINVAL
+	}
+	var offsets = append([]uint32(nil), req.Offsets[:req.NumLines]...)
+	for i, offset := range offsets {
+		if offset >= uint32(len(c.lines)) {
+			return unix.EINVAL
+		}
+		if l := &c.lines[offset]; l.busy || l.req != nil {
+			return unix.EBUSY
+		}
+		for _, o := range offsets[:i] {
+			if o == offset {
+				return unix.EBUSY
+			}
+		}
+	}
+	configs, err := c.lineConfigs(offsets, &req.Config)
+	if err != nil {
+		return err
+	}
+	fd, w, err := newPipe()
+	if err != nil {
+		return err
+	}
+	var r = &request{
+		w:         w,
+		consumer:  sys.Str32(req.Consumer),
+		offsets:   offsets,
+		lineSeqno: make([]uint32, len(offsets)),
+	}
+	c.requests[fd] = r
+	c.update(now, func() {
+		for i, offset := range offsets {
+			c.lines[offset].req = r
+			configs[i].apply(&c.lines[offset])
+		}
+	})
+	for _, offset := range offsets {
+		c.infoChanged(offset, sys.GPIO_V2_LINE_CHANGED_REQUESTED)
+	}
+	req.Fd = int32(fd)
+	return nil
+}
+
+// release releases the lines of request r. The released lines are inputs without bias.
+func (c *Chip) release(r *request) {
+	c.update(now, func() {
+		for _, offset := range r.offsets {
+			l := &c.lines[offset]
+			l.req = nil
+			l.flags = sys.GPIO_V2_LINE_FLAG_INPUT
+			l.debounceUs = 0
+			l.output = 0
+		}
+	})
+	for _, offset := range r.offsets {
+		c.infoChanged(offset, sys.GPIO_V2_LINE_CHANGED_RELEASED)
+	}
+}
+
+// lineInfo fills info with the information about the line at offset.
+func (c *Chip) lineInfo(offset uint32, info *sys.GPIOV2LineInfo) {
+	l := &c.lines[offset]
+	*info = sys.GPIOV2LineInfo{
+		Name:   sys.Char32(l.name),
+		Offset: offset,
+		Flags:  l.flags,
+	}
+	switch {
+	case l.req != nil:
+		info.Flags |= sys.GPIO_V2_LINE_FLAG_USED
+		info.Consumer = sys.Char32(l.req.consumer)
+	case l.busy:
+		info.Flags |= sys.GPIO_V2_LINE_FLAG_USED
+		info.Consumer = sys.Char32(l.busyConsumer)
+	}
+	if l.debounceUs != 0 {
+		info.Attrs[0].ID = sys.GPIO_V2_LINE_ATTR_ID_DEBOUNCE
+		info.Attrs[0].SetDebouncePeriodUs(l.debounceUs)
+		info.NumAttrs = 1
+	}
+}
+
+// infoChanged writes the line info change of the line at offset to the chip fds
+// watching the line.
+func (c *Chip) infoChanged(offset uint32, changeType uint32) {
+	var change = sys.GPIOV2LineInfoChanged{
+		TimestampNs: uint64(clockNow(unix.CLOCK_MONOTONIC)),
+		EventType:   changeType,
+	}
+	c.lineInfo(offset, &change.Info)
+	for _, cfd := range c.chipFds {
+		if cfd.watched[offset] {
+			writeStruct(cfd.w, unsafe.Pointer(&change), unsafe.Sizeof(change))
+		}
+	}
+}
@@ -0,0 +1,31 @@
+/*
+Package gpiotest implements simulated GPIO chips for testing code using package
+github.com/mkch/gpio without GPIO hardware.
+
+A simulated chip is opened with Chip.Open as a gpio.Chip, and works as a real
+chip supporting GPIO uAPI v2(Linux 5.10+): lines can be requested, read, written,
+reconfigured and watched, and edge events are delivered through the same channels.
+The test drives the input levels, observes the output levels, injects edges with
+chosen timestamps and simulates busy lines and permission errors with the methods
+of Chip.
+
+	chip, err := gpiotest.NewChip("sim0", "test-chip", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chip.Close()
+	c, err := chip.Open() // The *gpio.Chip used by the code under test.
+	...
+	line, err := c.RequestLineWithEvents(3)
+	...
+	chip.Drive(3, 1) // Rising edge event on line 3.
+
+The lines can be wired together with Chip.Connect to model the circuit, such as
+an output line connected to an input line with a propagation delay, or an
+open-drain bus with a pull-up resistor. The code bit-banging a protocol can then
+be tested in loopback.
+
+	chip.Wire(0, 1, time.Microsecond) // Output line 0 drives input line 1.
+	chip.Connect([]uint32{2, 3, 4}, gpiotest.WithPullUp()) // Wired-AND net.
+*/
+package gpiotest
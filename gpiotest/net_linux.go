@@ -0,0 +1,241 @@
+package gpiotest
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mkch/gpio/internal/sys"
+)
+
+// Net is a set of lines of a simulated chip wired together.
+//
+// The level of a net is decided by the lines driving it, which are the output
+// lines and the lines driven by Chip.Drive. The lines are wired-AND: the net is
+// low if any line drives it low, and high if some lines drive it high and no line
+// drives it low. Open-drain outputs only drive low, and open-source outputs only
+// drive high. If no line drives the net, the level is decided by the pull resistor
+// of the net, see WithPullUp and WithPullDown, or the bias of the lines. A floating
+// net keeps its level.
+//
+// The lines see the level changes of the net after the propagation delay of the
+// net, see WithDelay, and edge events are generated on the lines requested for
+// the edges. An output line actively driving the net sees its own output level.
+type Net struct {
+	chip    *Chip
+	offsets []uint32
+	delay   time.Duration
+	// The level of the external pull resistor, -1 if none.
+	pull int
+	// The level seen by the lines.
+	level byte
+	// The level changes to be seen by the lines after the delay, in time order.
+	pending []levelChange
+	timer   *time.Timer
+	// Whether the net is disconnected.
+	disconnected bool
+}
+
+// levelChange is a level change of a net seen by the lines at time.
+type levelChange struct {
+	time  time.Time
+	level byte
+}
+
+// NetOption is an option of Chip.Connect.
+type NetOption func(n *Net)
+
+// WithDelay sets the propagation delay of the net.
+// The level changes are seen by the lines immediately by default.
+func WithDelay(delay time.Duration) NetOption {
+	return func(n *Net) {
+		n.delay = delay
+	}
+}
+
+// WithPullUp connects the net to a pull-up resistor, such as the one of an
+// I2C bus, so the net is high if not driven.
+func WithPullUp() NetOption {
+	return func(n *Net) {
+		n.pull = 1
+	}
+}
+
+// WithPullDown connects the net to a pull-down resistor, so the net is low
+// if not driven.
+func WithPullDown() NetOption {
+	return func(n *Net) {
+		n.pull = 0
+	}
+}
+
+// Connect wires the lines at offsets together into a net, for example an output
+// line to an input line to test the code using both in loopback. A line can be
+// connected to only one net.
+func (c *Chip) Connect(offsets []uint32, options ...NetOption) (*Net, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(offsets) < 2 {
+		return nil, fmt.Errorf("connect lines %v of simulated chip %v failed: at least 2 lines are required", offsets, c.name)
+	}
+	for i, offset := range offsets {
+		if err := c.checkOffset("connect", offset); err != nil {
+			return nil, err
+		}
+		if c.lines[offset].net != nil {
+			return nil, fmt.Errorf("connect line %v of simulated chip %v failed: already connected", offset, c.name)
+		}
+		for _, o := range offsets[:i] {
+			if o == offset {
+				return nil, fmt.Errorf("connect line %v of simulated chip %v failed: duplicated", offset, c.name)
+			}
+		}
+	}
+	var n = &Net{chip: c, offsets: append([]uint32(nil), offsets...), pull: -1}
+	for _, option := range options {
+		option(n)
+	}
+	if n.delay < 0 {
+		return nil, fmt.Errorf("connect lines %v of simulated chip %v failed: negative delay %v", offsets, c.name, n.delay)
+	}
+	// The lines see the initial level of the net immediately.
+	n.level = c.netLevel(n)
+	c.update(now, func() {
+		for _, offset := range n.offsets {
+			c.lines[offset].net = n
+		}
+		c.nets = append(c.nets, n)
+	})
+	return n, nil
+}
+
+// Wire connects output line out to input line in with propagation delay.
+// It is a shortcut of Connect.
+func (c *Chip) Wire(out, in uint32, delay time.Duration) (*Net, error) {
+	return c.Connect([]uint32{out, in}, WithDelay(delay))
+}
+
+// Offsets returns the offsets of the lines of n.
+func (n *Net) Offsets() []uint32 {
+	return append([]uint32(nil), n.offsets...)
+}
+
+// Level returns the level of n seen by the lines.
+func (n *Net) Level() byte {
+	n.chip.lock.Lock()
+	defer n.chip.lock.Unlock()
+	return n.level
+}
+
+// Disconnect disconnects the lines of n, and the pending level changes are discarded.
+// The disconnected lines are no longer driven by the net.
+func (n *Net) Disconnect() error {
+	c := n.chip
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if n.disconnected {
+		return errors.New("disconnect net failed: already disconnected")
+	}
+	n.stop()
+	c.update(now, func() {
+		for _, offset := range n.offsets {
+			c.lines[offset].net = nil
+		}
+		for i, net := range c.nets {
+			if net == n {
+				c.nets = append(c.nets[:i], c.nets[i+1:]...)
+				break
+			}
+		}
+	})
+	return nil
+}
+
+// stop stops the pending level changes of n.
+func (n *Net) stop() {
+	n.disconnected = true
+	n.pending = nil
+	if n.timer != nil {
+		n.timer.Stop()
+	}
+}
+
+// target returns the level to be seen by the lines after the pending changes.
+func (n *Net) target() byte {
+	if len(n.pending) > 0 {
+		return n.pending[len(n.pending)-1].level
+	}
+	return n.level
+}
+
+// netLevel returns the level of n decided by the lines driving it now.
+func (c *Chip) netLevel(n *Net) byte {
+	var driven, low bool
+	for _, offset := range n.offsets {
+		l := &c.lines[offset]
+		level, ok := l.outputLevel()
+		if !ok && l.driven {
+			level, ok = l.drive, true
+		}
+		if ok {
+			driven = true
+			low = low || level == 0
+		}
+	}
+	switch {
+	case low:
+		return 0
+	case driven:
+		return 1
+	case n.pull >= 0:
+		return byte(n.pull)
+	}
+	for _, offset := range n.offsets {
+		switch flags := c.lines[offset].flags; {
+		case flags&sys.GPIO_V2_LINE_FLAG_BIAS_PULL_UP != 0:
+			return 1
+		case flags&sys.GPIO_V2_LINE_FLAG_BIAS_PULL_DOWN != 0:
+			return 0
+		}
+	}
+	return n.target()
+}
+
+// propagate propagates the level changes of the lines driving the nets.
+// The changes are seen by the lines immediately, or scheduled after the delay.
+func (c *Chip) propagate() {
+	for _, n := range c.nets {
+		level := c.netLevel(n)
+		if level == n.target() {
+			continue
+		}
+		if n.delay == 0 {
+			n.level = level
+			continue
+		}
+		n.pending = append(n.pending, levelChange{time: time.Now().Add(n.delay), level: level})
+		if len(n.pending) == 1 {
+			n.timer = time.AfterFunc(n.delay, n.fire)
+		}
+	}
+}
+
+// fire applies the due level changes of n, and schedules the next.
+func (n *Net) fire() {
+	c := n.chip
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if n.disconnected {
+		return
+	}
+	// Apply the changes one by one, so no edge is missed.
+	for t := time.Now(); len(n.pending) > 0 && !n.pending[0].time.After(t); {
+		c.update(now, func() {
+			n.level = n.pending[0].level
+			n.pending = n.pending[1:]
+		})
+	}
+	if len(n.pending) > 0 {
+		n.timer = time.AfterFunc(time.Until(n.pending[0].time), n.fire)
+	}
+}
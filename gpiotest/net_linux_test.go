@@ -0,0 +1,145 @@
+package gpiotest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/mkch/asserting"
+	"github.com/mkch/gpio"
+	"github.com/mkch/gpio/gpiotest"
+)
+
+func TestWire(t1 *testing.T) {
+	t := NewTB(t1)
+	sim, chip := newChip(t, "sim-wire", 2)
+	defer sim.Close()
+	defer chip.Close()
+
+	_, err := sim.Wire(0, 1, 0)
+	t.AssertNoError(err)
+	out, err := chip.RequestLine(0, gpio.WithOutput(0))
+	t.AssertNoError(err)
+	defer out.Close()
+	in, err := chip.RequestLineWithEvents(1)
+	t.AssertNoError(err)
+	defer in.Close()
+
+	t.AssertNoError(out.SetValue(1))
+	value, err := in.Value()
+	t.AssertNoError(err)
+	t.AssertEqual(value, byte(1))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = in.WaitEdge(ctx, gpio.RisingEdge)
+	t.AssertNoError(err)
+}
+
+func TestWiredAnd(t1 *testing.T) {
+	t := NewTB(t1)
+	sim, chip := newChip(t, "sim-wired-and", 3)
+	defer sim.Close()
+	defer chip.Close()
+
+	net, err := sim.Connect([]uint32{0, 1, 2}, gpiotest.WithPullUp())
+	t.AssertNoError(err)
+	t.AssertEqual(net.Level(), byte(1))
+	outs, err := chip.RequestLines([]uint32{0, 1}, gpio.WithOutput(1, 1), gpio.WithDrive(gpio.OpenDrain))
+	t.AssertNoError(err)
+	defer outs.Close()
+	in, err := chip.RequestLine(2)
+	t.AssertNoError(err)
+	defer in.Close()
+
+	var expected = []struct {
+		outs []byte
+		in   byte
+	}{
+		{[]byte{1, 1}, 1},
+		{[]byte{0, 1}, 0},
+		{[]byte{0, 0}, 0},
+		{[]byte{1, 0}, 0},
+		{[]byte{1, 1}, 1},
+	}
+	for _, e := range expected {
+		t.AssertNoError(outs.SetValues(e.outs))
+		value, err := in.Value()
+		t.AssertNoError(err)
+		t.AssertEqual(value, e.in)
+	}
+	// Released open-drain outputs read the net.
+	values, err := outs.Values()
+	t.AssertNoError(err)
+	t.AssertEqualSlice(values, []byte{1, 1})
+
+	// Another device pulls the net low, such as I2C clock stretching.
+	t.AssertNoError(sim.Drive(2, 0))
+	values, err = outs.Values()
+	t.AssertNoError(err)
+	t.AssertEqualSlice(values, []byte{0, 0})
+	t.AssertNoError(sim.Float(2))
+	t.AssertEqual(net.Level(), byte(1))
+
+	t.AssertNoError(net.Disconnect())
+	t.Assert(net.Disconnect(), NotEquals(nil))
+	value, err := in.Value()
+	t.AssertNoError(err)
+	t.AssertEqual(value, byte(0))
+}
+
+func TestWireDelay(t1 *testing.T) {
+	t := NewTB(t1)
+	sim, chip := newChip(t, "sim-delay", 2)
+	defer sim.Close()
+	defer chip.Close()
+
+	const delay = 50 * time.Millisecond
+	net, err := sim.Wire(0, 1, delay)
+	t.AssertNoError(err)
+	out, err := chip.RequestLine(0, gpio.WithOutput(0))
+	t.AssertNoError(err)
+	defer out.Close()
+	in, err := chip.RequestLineWithEvents(1)
+	t.AssertNoError(err)
+	defer in.Close()
+
+	start := time.Now()
+	t.AssertNoError(out.SetValue(1))
+	t.AssertNoError(out.SetValue(0))
+	t.AssertNoError(out.SetValue(1))
+	value, err := in.Value()
+	t.AssertNoError(err)
+	t.AssertEqual(value, byte(0))
+	// The pulse is delayed too.
+	for _, rising := range []bool{true, false, true} {
+		select {
+		case event := <-in.Events():
+			t.AssertEqual(event.RisingEdge, rising)
+		case <-time.After(time.Second):
+			t1.Fatal("no event")
+		}
+	}
+	t.AssertTrue(time.Since(start) >= delay)
+	t.AssertEqual(net.Level(), byte(1))
+}
+
+func TestConnectError(t1 *testing.T) {
+	t := NewTB(t1)
+	sim, err := gpiotest.NewChip("sim-connect", "", 4)
+	t.AssertNoError(err)
+	defer sim.Close()
+
+	_, err = sim.Connect([]uint32{0})
+	t.Assert(err, NotEquals(nil))
+	_, err = sim.Connect([]uint32{0, 0})
+	t.Assert(err, NotEquals(nil))
+	_, err = sim.Connect([]uint32{0, 4})
+	t.Assert(err, NotEquals(nil))
+	_, err = sim.Wire(0, 1, -time.Second)
+	t.Assert(err, NotEquals(nil))
+	net, err := sim.Connect([]uint32{0, 1})
+	t.AssertNoError(err)
+	t.AssertEqualSlice(net.Offsets(), []uint32{0, 1})
+	_, err = sim.Wire(1, 2, 0)
+	t.Assert(err, NotEquals(nil))
+}
@@ -0,0 +1,56 @@
+package gpio
+
+import (
+	"context"
+)
+
+// The interfaces below are implemented by both the GPIO lines of this package
+// and the pins of package gpiosysfs, so drivers can work with either of them.
+// Package github.com/mkch/gpio/sysfsadapter adapts the pins of gpiosysfs where
+// the methods differ, so this package does not depend on gpiosysfs.
+
+// Reader is the interface to read the value of a GPIO line.
+// It is implemented by *Line, *LineWithEvent, *gpiosysfs.Pin and *gpiosysfs.PinWithEvent.
+type Reader interface {
+	// Value returns the current value of the GPIO line. 1 (high) or 0 (low).
+	Value() (value byte, err error)
+}
+
+// Writer is the interface to set the value of a GPIO line.
+// It is implemented by *Line and *gpiosysfs.Pin.
+type Writer interface {
+	// SetValue sets the value of the GPIO line.
+	// Value should be 0 (low) or 1 (high), anything else than 0 will be interpreted as 1 (high).
+	SetValue(value byte) error
+}
+
+// DirectionSetter is the interface to change the direction of a GPIO line.
+// It is implemented by *Line and *gpiosysfs.Pin.
+type DirectionSetter interface {
+	// SetInput configures the GPIO line as input.
+	SetInput() error
+	// SetOutput configures the GPIO line as output and sets the value.
+	SetOutput(value byte) error
+}
+
+// EdgeWatcher is the interface to receive the edge events of a GPIO line.
+// It is implemented by *LineWithEvent, and *gpiosysfs.PinWithEvent can be
+// adapted by sysfsadapter.EdgeWatcher.
+type EdgeWatcher interface {
+	Reader
+	// Events returns the channel from which the events can be read.
+	Events() <-chan *Event
+	// Err returns the error that caused the channel returned by Events to be closed.
+	Err() error
+	// WaitEdge waits for the next event of edge, RisingEdge, FallingEdge or BothEdges.
+	WaitEdge(ctx context.Context, edge EventFlag) (*Event, error)
+	// WaitValue waits until the value of the line becomes value.
+	WaitValue(ctx context.Context, value byte) (*Event, error)
+}
+
+var (
+	_ Reader          = (*Line)(nil)
+	_ Writer          = (*Line)(nil)
+	_ DirectionSetter = (*Line)(nil)
+	_ EdgeWatcher     = (*LineWithEvent)(nil)
+)
@@ -14,6 +14,16 @@ var (
 	GPIOHANDLE_SET_LINE_VALUES_IOCTL = uint32(C.GPIOHANDLE_SET_LINE_VALUES_IOCTL)
 	GPIOHANDLE_GET_LINE_VALUES_IOCTL = uint32(C.GPIOHANDLE_GET_LINE_VALUES_IOCTL)
 	GPIO_GET_LINEEVENT_IOCTL         = uint32(C.GPIO_GET_LINEEVENT_IOCTL)
+	GPIOHANDLE_SET_CONFIG_IOCTL      = uint32(C.GPIOHANDLE_SET_CONFIG_IOCTL)
+	GPIO_GET_LINEINFO_WATCH_IOCTL    = uint32(C.GPIO_GET_LINEINFO_WATCH_IOCTL)
+	GPIO_GET_LINEINFO_UNWATCH_IOCTL  = uint32(C.GPIO_GET_LINEINFO_UNWATCH_IOCTL)
+
+	GPIO_V2_GET_LINEINFO_IOCTL       = uint32(C.GPIO_V2_GET_LINEINFO_IOCTL)
+	GPIO_V2_GET_LINEINFO_WATCH_IOCTL = uint32(C.GPIO_V2_GET_LINEINFO_WATCH_IOCTL)
+	GPIO_V2_GET_LINE_IOCTL           = uint32(C.GPIO_V2_GET_LINE_IOCTL)
+	GPIO_V2_LINE_SET_CONFIG_IOCTL    = uint32(C.GPIO_V2_LINE_SET_CONFIG_IOCTL)
+	GPIO_V2_LINE_GET_VALUES_IOCTL    = uint32(C.GPIO_V2_LINE_GET_VALUES_IOCTL)
+	GPIO_V2_LINE_SET_VALUES_IOCTL    = uint32(C.GPIO_V2_LINE_SET_VALUES_IOCTL)
 )
 
 var (
@@ -22,6 +32,16 @@ var (
 	GPIOLINE_FLAG_ACTIVE_LOW  = uint32(C.GPIOLINE_FLAG_ACTIVE_LOW)
 	GPIOLINE_FLAG_OPEN_DRAIN  = uint32(C.GPIOLINE_FLAG_OPEN_DRAIN)
 	GPIOLINE_FLAG_OPEN_SOURCE = uint32(C.GPIOLINE_FLAG_OPEN_SOURCE)
+
+	GPIOLINE_FLAG_BIAS_PULL_UP   = uint32(C.GPIOLINE_FLAG_BIAS_PULL_UP)
+	GPIOLINE_FLAG_BIAS_PULL_DOWN = uint32(C.GPIOLINE_FLAG_BIAS_PULL_DOWN)
+	GPIOLINE_FLAG_BIAS_DISABLE   = uint32(C.GPIOLINE_FLAG_BIAS_DISABLE)
+)
+
+var (
+	GPIOLINE_CHANGED_REQUESTED = uint32(C.GPIOLINE_CHANGED_REQUESTED)
+	GPIOLINE_CHANGED_RELEASED  = uint32(C.GPIOLINE_CHANGED_RELEASED)
+	GPIOLINE_CHANGED_CONFIG    = uint32(C.GPIOLINE_CHANGED_CONFIG)
 )
 
 var (
@@ -30,6 +50,10 @@ var (
 	GPIOHANDLE_REQUEST_ACTIVE_LOW  = uint32(C.GPIOHANDLE_REQUEST_ACTIVE_LOW)
 	GPIOHANDLE_REQUEST_OPEN_DRAIN  = uint32(C.GPIOHANDLE_REQUEST_OPEN_DRAIN)
 	GPIOHANDLE_REQUEST_OPEN_SOURCE = uint32(C.GPIOHANDLE_REQUEST_OPEN_SOURCE)
+
+	GPIOHANDLE_REQUEST_BIAS_PULL_UP   = uint32(C.GPIOHANDLE_REQUEST_BIAS_PULL_UP)
+	GPIOHANDLE_REQUEST_BIAS_PULL_DOWN = uint32(C.GPIOHANDLE_REQUEST_BIAS_PULL_DOWN)
+	GPIOHANDLE_REQUEST_BIAS_DISABLE   = uint32(C.GPIOHANDLE_REQUEST_BIAS_DISABLE)
 )
 
 var (
@@ -40,6 +64,55 @@ var (
 
 type GPIOChipInfo = C.struct_gpiochip_info
 type GPIOLineInfo = C.struct_gpioline_info
+type GPIOLineInfoChanged = C.struct_gpioline_info_changed
 type GPIOHandleRequest = C.struct_gpiohandle_request
+type GPIOHandleConfig = C.struct_gpiohandle_config
 type GPIOEventRequest = C.struct_gpioevent_request
 type GPIOEventData = C.struct_gpioevent_data
+
+var (
+	GPIO_V2_LINES_MAX          = uint64(C.GPIO_V2_LINES_MAX)
+	GPIO_V2_LINE_NUM_ATTRS_MAX = uint64(C.GPIO_V2_LINE_NUM_ATTRS_MAX)
+)
+
+var (
+	GPIO_V2_LINE_FLAG_USED                 = uint64(C.GPIO_V2_LINE_FLAG_USED)
+	GPIO_V2_LINE_FLAG_ACTIVE_LOW           = uint64(C.GPIO_V2_LINE_FLAG_ACTIVE_LOW)
+	GPIO_V2_LINE_FLAG_INPUT                = uint64(C.GPIO_V2_LINE_FLAG_INPUT)
+	GPIO_V2_LINE_FLAG_OUTPUT               = uint64(C.GPIO_V2_LINE_FLAG_OUTPUT)
+	GPIO_V2_LINE_FLAG_EDGE_RISING          = uint64(C.GPIO_V2_LINE_FLAG_EDGE_RISING)
+	GPIO_V2_LINE_FLAG_EDGE_FALLING         = uint64(C.GPIO_V2_LINE_FLAG_EDGE_FALLING)
+	GPIO_V2_LINE_FLAG_OPEN_DRAIN           = uint64(C.GPIO_V2_LINE_FLAG_OPEN_DRAIN)
+	GPIO_V2_LINE_FLAG_OPEN_SOURCE          = uint64(C.GPIO_V2_LINE_FLAG_OPEN_SOURCE)
+	GPIO_V2_LINE_FLAG_BIAS_PULL_UP         = uint64(C.GPIO_V2_LINE_FLAG_BIAS_PULL_UP)
+	GPIO_V2_LINE_FLAG_BIAS_PULL_DOWN       = uint64(C.GPIO_V2_LINE_FLAG_BIAS_PULL_DOWN)
+	GPIO_V2_LINE_FLAG_BIAS_DISABLED        = uint64(C.GPIO_V2_LINE_FLAG_BIAS_DISABLED)
+	GPIO_V2_LINE_FLAG_EVENT_CLOCK_REALTIME = uint64(C.GPIO_V2_LINE_FLAG_EVENT_CLOCK_REALTIME)
+	GPIO_V2_LINE_FLAG_EVENT_CLOCK_HTE      = uint64(C.GPIO_V2_LINE_FLAG_EVENT_CLOCK_HTE)
+)
+
+var (
+	GPIO_V2_LINE_ATTR_ID_FLAGS         = uint64(C.GPIO_V2_LINE_ATTR_ID_FLAGS)
+	GPIO_V2_LINE_ATTR_ID_OUTPUT_VALUES = uint64(C.GPIO_V2_LINE_ATTR_ID_OUTPUT_VALUES)
+	GPIO_V2_LINE_ATTR_ID_DEBOUNCE      = uint64(C.GPIO_V2_LINE_ATTR_ID_DEBOUNCE)
+)
+
+var (
+	GPIO_V2_LINE_CHANGED_REQUESTED = uint64(C.GPIO_V2_LINE_CHANGED_REQUESTED)
+	GPIO_V2_LINE_CHANGED_RELEASED  = uint64(C.GPIO_V2_LINE_CHANGED_RELEASED)
+	GPIO_V2_LINE_CHANGED_CONFIG    = uint64(C.GPIO_V2_LINE_CHANGED_CONFIG)
+)
+
+var (
+	GPIO_V2_LINE_EVENT_RISING_EDGE  = uint64(C.GPIO_V2_LINE_EVENT_RISING_EDGE)
+	GPIO_V2_LINE_EVENT_FALLING_EDGE = uint64(C.GPIO_V2_LINE_EVENT_FALLING_EDGE)
+)
+
+type GPIOV2LineValues = C.struct_gpio_v2_line_values
+type GPIOV2LineAttribute = C.struct_gpio_v2_line_attribute
+type GPIOV2LineConfigAttribute = C.struct_gpio_v2_line_config_attribute
+type GPIOV2LineConfig = C.struct_gpio_v2_line_config
+type GPIOV2LineRequest = C.struct_gpio_v2_line_request
+type GPIOV2LineInfo = C.struct_gpio_v2_line_info
+type GPIOV2LineInfoChanged = C.struct_gpio_v2_line_info_changed
+type GPIOV2LineEvent = C.struct_gpio_v2_line_event
@@ -1,176 +1,218 @@
-// Package fdevents implements epoll_wait loop for GPIO events and exposes a channel interface.
+// Package fdevents implements a shared epoll_wait loop for GPIO events and exposes a channel interface.
 package fdevents
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"runtime"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"time"
-	"unsafe"
-
-	"golang.org/x/sys/unix"
 )
 
 // Event is a GPIO event.
 type Event struct {
 	RisingEdge bool      // Whether this event is triggered by a rising edge.
 	Time       time.Time // The best estimate of time of event occurrence.
+	// The raw timestamp of this event read from the event clock of the kernel,
+	// the elapsed time since the epoch of that clock.
+	// 0 if not available(sysfs).
+	Timestamp time.Duration
+	// The sequence number of this event in the sequence of events of all
+	// the lines in the same request, starting from 1.
+	// 0 if not available(GPIO uAPI v1 or sysfs).
+	Seqno uint32
+	// The sequence number of this event in the sequence of events on this line,
+	// starting from 1. 0 if not available(GPIO uAPI v1 or sysfs).
+	// A gap between the LineSeqno of two consecutive received events means events
+	// lost, either overflowed in the kernel or overwritten in the event channel.
+	LineSeqno uint32
+	// The offset of the line on which this event occurred.
+	// 0 if not available(sysfs).
+	Offset uint32
 }
 
-type ReadFdFunc func(fd int) *Event
+// ReadFdFunc reads an event from a ready fd.
+// It returns nil Event and nil error if there is no event to send, such as
+// the read is interrupted. A non-nil error stops the epoll_wait loop.
+type ReadFdFunc func(fd int) (*Event, error)
+
+// OverflowPolicy decides what to do with a new event when the event channel is full.
+type OverflowPolicy int
+
+const (
+	// OverflowDropOldest discards the oldest unread event in the channel to make room.
+	// With a channel buffer of 1, only the latest event is kept.
+	OverflowDropOldest OverflowPolicy = iota
+	// OverflowDropNewest discards the new event.
+	OverflowDropNewest
+	// OverflowBlock waits until there is room in the channel. Further events are
+	// buffered in the kernel meanwhile, and may get lost there.
+	OverflowBlock
+	// OverflowError closes the channel, and Err returns ErrOverflow.
+	OverflowError
+)
+
+// ErrOverflow is the error reported if the event channel overflows with OverflowError policy.
+var ErrOverflow = errors.New("event channel overflow")
+
+// ErrClosed is the error returned by Close if already closed, and by Wait if
+// the event channel is closed by Close.
+var ErrClosed = errors.New("already closed")
 
 // FdEvents converts epoll_wait loops to a chanel.
 type FdEvents struct {
-	events              chan *Event
-	waitLoopDone        sync.WaitGroup
-	exitWaitLoopEventFd int
-	closed              bool
+	// The number of discarded events. Accessed atomically.
+	// Keep it the first field to be 64-bit aligned.
+	overwritten uint64
+	events      chan *Event
+	policy      OverflowPolicy
+	watcher     *Watcher
+	// Closed by Close to stop blocking sending to events.
+	closing   chan struct{}
+	closeOnce sync.Once
+	errLock   sync.Mutex
+	err       error
 }
 
 // New creates a FdEvents and returns any error encountered.
 // The returned FdEvents waits fd for fdEpollEvents in a epoll_wait loop.
 // If epoll_wait returns successfully, readFd is called to generate a value,
 // and that value is sent to the event channel returned by Events.
+// If readFd or epoll_wait fails, the loop stops, the event channel is closed,
+// and Err returns the error.
 //
 // readFd should return the best estimate of time of event occurrence.
 //
 // Package fdevents will not block sending to the channel: it only keeps the lastest
 // value in the channel.
 func New(fd int, closeFdOnClose bool, fdEpollEvents uint32, readFd ReadFdFunc) (events *FdEvents, err error) {
-	wakeUpEventFd, err := unix.Eventfd(0, 0)
-	if err != nil {
-		err = fmt.Errorf("request GPIO event failed: eventfd: %w", err)
-		return
-	}
-
-	epollFd, err := unix.EpollCreate(1)
-	if err != nil {
-		err = fmt.Errorf("request GPIO event failed: epoll_create: %w", err)
-		unix.Close(wakeUpEventFd)
-		return
-	}
+	return NewBuffered(fd, closeFdOnClose, fdEpollEvents, readFd, 1, OverflowDropOldest)
+}
 
-	err = unix.EpollCtl(epollFd, unix.EPOLL_CTL_ADD, wakeUpEventFd, &unix.EpollEvent{
-		Events: unix.EPOLLIN,
-		Fd:     int32(wakeUpEventFd),
-	})
-	if err != nil {
-		err = fmt.Errorf("request GPIO event failed: epoll_ctl %w", err)
-		unix.Close(wakeUpEventFd)
-		unix.Close(epollFd)
+// NewBuffered is like New, but the event channel has a buffer of bufferSize,
+// and policy decides what to do when the channel is full.
+func NewBuffered(fd int, closeFdOnClose bool, fdEpollEvents uint32, readFd ReadFdFunc, bufferSize int, policy OverflowPolicy) (events *FdEvents, err error) {
+	if bufferSize < 1 {
+		err = fmt.Errorf("invalid event buffer size %v", bufferSize)
 		return
 	}
-
-	err = unix.EpollCtl(epollFd, unix.EPOLL_CTL_ADD, int(fd), &unix.EpollEvent{
-		Events: fdEpollEvents,
-		Fd:     int32(fd),
-	})
-	if err != nil {
-		err = fmt.Errorf("request GPIO event failed: epoll_ctl %w", err)
-		unix.Close(wakeUpEventFd)
-		unix.Close(epollFd)
+	if policy < OverflowDropOldest || policy > OverflowError {
+		err = fmt.Errorf("invalid overflow policy %v", policy)
 		return
 	}
-
 	events = &FdEvents{
-		events:              make(chan *Event, 1), // Buffer 1 to store the latest.
-		exitWaitLoopEventFd: wakeUpEventFd,
+		events:  make(chan *Event, bufferSize),
+		policy:  policy,
+		closing: make(chan struct{}),
 	}
-	runtime.SetFinalizer(events, func(p *FdEvents) { p.Close() })
-
-	events.waitLoopDone.Add(1)
-	go events.waitLoop(fd, closeFdOnClose, epollFd, readFd)
-	return
-}
-
-func (events *FdEvents) waitLoop(fd int, closeFdOnClose bool, epollFd int, readFd ReadFdFunc) {
-	defer func() {
-		err := unix.Close(events.exitWaitLoopEventFd)
-		if err != nil {
-			panic(fmt.Errorf("failed to call close: %w", err))
+	events.watcher, err = Watch(fd, closeFdOnClose, fdEpollEvents, func(fd int) error {
+		e, err := readFd(fd)
+		if err != nil || e == nil {
+			return err
 		}
-		err = unix.Close(epollFd)
+		return events.send(e)
+	}, func(err error) {
 		if err != nil {
-			panic(fmt.Errorf("failed to call close: %w", err))
-		}
-		if closeFdOnClose {
-			err = unix.Close(fd)
-			if err != nil {
-				panic(fmt.Errorf("failed to call close: %w", err))
-			}
+			events.errLock.Lock()
+			events.err = err
+			events.errLock.Unlock()
 		}
 		close(events.events)
-		events.waitLoopDone.Done()
-	}()
-
-	var waitEvent [2]unix.EpollEvent
-epoll_wait_loop:
-	for {
-		n, err := unix.EpollWait(epollFd, waitEvent[:], -1)
-		if err != nil {
-			if err == syscall.EINTR {
-				continue
-			}
-			panic(fmt.Errorf("failed to call epoll_wait: %w", err))
-		}
-		for i := 0; i < n; i++ {
-			switch waitEvent[i].Fd {
-			case int32(fd):
-				// Interrupt caused by GPIO event.
-				t := readFd(fd)
-				if t == nil {
-					continue
-				}
-				// Discard the unread old value.
-				select {
-				case <-events.events:
-				default:
-				}
-				// Send the latest.
-				events.events <- t
-			case int32(events.exitWaitLoopEventFd):
-				break epoll_wait_loop
-			}
-		}
+	})
+	if err != nil {
+		events = nil
+		return
 	}
+	runtime.SetFinalizer(events, func(p *FdEvents) { p.Close() })
+	return
 }
 
-func (events *FdEvents) notifyWaitLoopToExit() (err error) {
-	// Wakeup epoll_wait loop adding 1 to the event counter.
-	var one = uint64(1)
-	n, err := unix.Write(events.exitWaitLoopEventFd, (*[unsafe.Sizeof(one)]byte)(unsafe.Pointer(&one))[:])
-	if err != nil {
-		err = fmt.Errorf("failed to write to event fd: %w", err)
-		return
+// send sends e to the event channel according to the overflow policy.
+// It returns ErrOverflow if the channel is full and the policy is OverflowError.
+func (events *FdEvents) send(e *Event) error {
+	select {
+	case events.events <- e:
+		return nil
+	default:
 	}
-	if n != int(unsafe.Sizeof(one)) {
-		err = fmt.Errorf("failed to write to event fd: short write: %v out of %v", n, unsafe.Sizeof(one))
+	// The channel is full.
+	switch events.policy {
+	case OverflowDropOldest:
+		// Discard the unread old value.
+		select {
+		case <-events.events:
+			atomic.AddUint64(&events.overwritten, 1)
+		default:
+		}
+		// Send the latest.
+		events.events <- e
+	case OverflowDropNewest:
+		atomic.AddUint64(&events.overwritten, 1)
+	case OverflowBlock:
+		select {
+		case events.events <- e:
+		case <-events.closing:
+		}
+	case OverflowError:
+		return ErrOverflow
 	}
-	return
+	return nil
 }
 
 // Close stops the epoll_wait loop, close the fd, and close the event channel.
+// It is safe to call Close concurrently. Close returns ErrClosed if already closed.
 func (events *FdEvents) Close() (err error) {
-	if events.closed {
-		return errors.New("already closed")
-	}
-	events.closed = true
-	err = events.notifyWaitLoopToExit()
-	if err != nil {
-		return
-	}
-	events.waitLoopDone.Wait()
-	return
+	events.closeOnce.Do(func() { close(events.closing) })
+	return events.watcher.Close()
 }
 
 // Events returns a channel from which the occurrence time of events can be read.
 // The best estimate of time of event occurrence is sent to the returned channel,
 // and the channel is closed when events is closed.
 //
-// Package fdevents will not block sending to the channel: it only keeps the lastest
-// value in the channel.
+// Unless created by NewBuffered, package fdevents will not block sending to the
+// channel: it only keeps the lastest value in the channel.
 func (events *FdEvents) Events() <-chan *Event {
 	return events.events
 }
+
+// Overwritten returns the number of events discarded because the event channel
+// was full.
+func (events *FdEvents) Overwritten() uint64 {
+	return atomic.LoadUint64(&events.overwritten)
+}
+
+// Err returns the error that caused the event channel to be closed, such as
+// ErrOverflow or the error of reading fd.
+// It returns nil if the channel is open or closed by Close.
+func (events *FdEvents) Err() error {
+	events.errLock.Lock()
+	defer events.errLock.Unlock()
+	return events.err
+}
+
+// Wait receives events from the event channel until match returns true, and returns
+// the matching event. Events not matching are discarded.
+// If ctx is done first, Wait returns ctx.Err().
+// If the channel is closed first, Wait returns the error returned by Err,
+// or ErrClosed if Err returns nil.
+func (events *FdEvents) Wait(ctx context.Context, match func(e *Event) bool) (*Event, error) {
+	for {
+		select {
+		case e, ok := <-events.events:
+			if !ok {
+				if err := events.Err(); err != nil {
+					return nil, err
+				}
+				return nil, ErrClosed
+			}
+			if match(e) {
+				return e, nil
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package fdevents_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
@@ -36,11 +38,11 @@ func TestFdEvents(t1 *testing.T) {
 		t.AssertNoError(err)
 	}()
 
-	events, err := fdevents.New(pipe[0], false, unix.EPOLLIN, func(fd int) *fdevents.Event {
+	events, err := fdevents.New(pipe[0], false, unix.EPOLLIN, func(fd int) (*fdevents.Event, error) {
 		var v int64
 		_, err := io.ReadFull(sys.FdReader(fd), (*[unsafe.Sizeof(v)]byte)(unsafe.Pointer(&v))[:])
 		t.AssertNoError(err)
-		return &fdevents.Event{Time: time.Unix(v, 0)}
+		return &fdevents.Event{Time: time.Unix(v, 0)}, nil
 	})
 	t.AssertNoError(err)
 
@@ -84,11 +86,11 @@ func TestFdEventsClose(t1 *testing.T) {
 		t.AssertNoError(err)
 	}()
 
-	events, err := fdevents.New(pipe[0], true /*close fd on close*/, unix.EPOLLIN, func(fd int) *fdevents.Event {
+	events, err := fdevents.New(pipe[0], true /*close fd on close*/, unix.EPOLLIN, func(fd int) (*fdevents.Event, error) {
 		var v int64
 		_, err := io.ReadFull(sys.FdReader(fd), (*[unsafe.Sizeof(v)]byte)(unsafe.Pointer(&v))[:])
 		t.AssertNoError(err)
-		return &fdevents.Event{Time: time.Unix(v, 0)}
+		return &fdevents.Event{Time: time.Unix(v, 0)}, nil
 	})
 	t.AssertNoError(err)
 
@@ -106,3 +108,259 @@ for_loop:
 	}
 
 }
+
+func TestWatcher(t1 *testing.T) {
+	t := NewTB(t1)
+
+	var pipe [2]int
+	t.AssertNoError(unix.Pipe(pipe[:]))
+	// pipe[0] will be closed by watcher.
+	defer unix.Close(pipe[1])
+
+	var ready = make(chan int64)
+	var exited = make(chan struct{})
+	watcher, err := fdevents.Watch(pipe[0], true /*close fd on close*/, unix.EPOLLIN, func(fd int) error {
+		var v int64
+		_, err := io.ReadFull(sys.FdReader(fd), (*[unsafe.Sizeof(v)]byte)(unsafe.Pointer(&v))[:])
+		t.AssertNoError(err)
+		ready <- v
+		return nil
+	}, func(err error) {
+		t.AssertNoError(err)
+		close(exited)
+	})
+	t.AssertNoError(err)
+
+	for i := int64(1); i <= 3; i++ {
+		_, err := unix.Write(pipe[1], (*[unsafe.Sizeof(i)]byte)(unsafe.Pointer(&i))[:])
+		t.AssertNoError(err)
+		t.AssertEqual(<-ready, i)
+	}
+
+	t.AssertNoError(watcher.Close())
+	select {
+	case <-exited:
+	default:
+		t1.Fatal("onExit is not called when Close returns")
+	}
+	t.Assert(watcher.Close(), NotEquals(nil))
+}
+
+func TestFdEventsOverwritten(t1 *testing.T) {
+	t := NewTB(t1)
+
+	var pipe [2]int
+	t.AssertNoError(unix.Pipe(pipe[:]))
+	defer unix.Close(pipe[1])
+
+	for v := int64(1); v <= 3; v++ {
+		_, err := unix.Write(pipe[1], (*[unsafe.Sizeof(v)]byte)(unsafe.Pointer(&v))[:])
+		t.AssertNoError(err)
+	}
+
+	events, err := fdevents.New(pipe[0], true /*close fd on close*/, unix.EPOLLIN, func(fd int) (*fdevents.Event, error) {
+		var v int64
+		_, err := io.ReadFull(sys.FdReader(fd), (*[unsafe.Sizeof(v)]byte)(unsafe.Pointer(&v))[:])
+		t.AssertNoError(err)
+		return &fdevents.Event{Time: time.Unix(v, 0)}, nil
+	})
+	t.AssertNoError(err)
+	defer func() { t.AssertNoError(events.Close()) }()
+
+	for timeout := time.After(time.Second * 5); events.Overwritten() != 2; {
+		select {
+		case <-timeout:
+			t1.Fatalf("Overwritten() = %v, want 2", events.Overwritten())
+		default:
+			time.Sleep(time.Millisecond)
+		}
+	}
+	t.AssertEqual((<-events.Events()).Time.Unix(), int64(3))
+}
+
+func TestFdEventsOverflowPolicy(t1 *testing.T) {
+	newEvents := func(t TB, policy fdevents.OverflowPolicy) (events *fdevents.FdEvents, writeFd int) {
+		var pipe [2]int
+		t.AssertNoError(unix.Pipe(pipe[:]))
+		for v := int64(1); v <= 3; v++ {
+			_, err := unix.Write(pipe[1], (*[unsafe.Sizeof(v)]byte)(unsafe.Pointer(&v))[:])
+			t.AssertNoError(err)
+		}
+		events, err := fdevents.NewBuffered(pipe[0], true /*close fd on close*/, unix.EPOLLIN, func(fd int) (*fdevents.Event, error) {
+			var v int64
+			_, err := io.ReadFull(sys.FdReader(fd), (*[unsafe.Sizeof(v)]byte)(unsafe.Pointer(&v))[:])
+			t.AssertNoError(err)
+			return &fdevents.Event{Time: time.Unix(v, 0)}, nil
+		}, 2, policy)
+		t.AssertNoError(err)
+		return events, pipe[1]
+	}
+	waitOverwritten := func(t1 *testing.T, events *fdevents.FdEvents, n uint64) {
+		for timeout := time.After(time.Second * 5); events.Overwritten() != n; {
+			select {
+			case <-timeout:
+				t1.Fatalf("Overwritten() = %v, want %v", events.Overwritten(), n)
+			default:
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}
+	receive := func(events *fdevents.FdEvents) (values []int64) {
+		for {
+			select {
+			case e, ok := <-events.Events():
+				if !ok {
+					return
+				}
+				values = append(values, e.Time.Unix())
+			case <-time.After(time.Millisecond * 100):
+				return
+			}
+		}
+	}
+
+	t1.Run("drop-oldest", func(t1 *testing.T) {
+		t := NewTB(t1)
+		events, writeFd := newEvents(t, fdevents.OverflowDropOldest)
+		defer unix.Close(writeFd)
+		defer events.Close()
+		waitOverwritten(t1, events, 1)
+		t.AssertEqualSlice(receive(events), []int64{2, 3})
+	})
+
+	t1.Run("drop-newest", func(t1 *testing.T) {
+		t := NewTB(t1)
+		events, writeFd := newEvents(t, fdevents.OverflowDropNewest)
+		defer unix.Close(writeFd)
+		defer events.Close()
+		waitOverwritten(t1, events, 1)
+		t.AssertEqualSlice(receive(events), []int64{1, 2})
+	})
+
+	t1.Run("block", func(t1 *testing.T) {
+		t := NewTB(t1)
+		events, writeFd := newEvents(t, fdevents.OverflowBlock)
+		defer unix.Close(writeFd)
+		time.Sleep(time.Millisecond * 10)
+		t.AssertEqualSlice(receive(events), []int64{1, 2, 3})
+		t.AssertEqual(events.Overwritten(), uint64(0))
+		// Close while blocking on sending.
+		for v := int64(4); v <= 7; v++ {
+			_, err := unix.Write(writeFd, (*[unsafe.Sizeof(v)]byte)(unsafe.Pointer(&v))[:])
+			t.AssertNoError(err)
+		}
+		time.Sleep(time.Millisecond * 10)
+		t.AssertNoError(events.Close())
+	})
+
+	t1.Run("error", func(t1 *testing.T) {
+		t := NewTB(t1)
+		events, writeFd := newEvents(t, fdevents.OverflowError)
+		defer unix.Close(writeFd)
+		defer events.Close()
+		for timeout := time.After(time.Second * 5); events.Err() == nil; {
+			select {
+			case <-timeout:
+				t1.Fatal("no overflow error")
+			default:
+				time.Sleep(time.Millisecond)
+			}
+		}
+		t.AssertEqualSlice(receive(events), []int64{1, 2})
+		_, ok := <-events.Events()
+		t.AssertEqual(ok, false)
+		t.AssertEqual(events.Err(), fdevents.ErrOverflow)
+	})
+}
+
+func TestFdEventsWait(t1 *testing.T) {
+	t := NewTB(t1)
+
+	var pipe [2]int
+	t.AssertNoError(unix.Pipe(pipe[:]))
+	defer unix.Close(pipe[1])
+	for v := int64(1); v <= 4; v++ {
+		_, err := unix.Write(pipe[1], (*[unsafe.Sizeof(v)]byte)(unsafe.Pointer(&v))[:])
+		t.AssertNoError(err)
+	}
+	events, err := fdevents.NewBuffered(pipe[0], true /*close fd on close*/, unix.EPOLLIN, func(fd int) (*fdevents.Event, error) {
+		var v int64
+		_, err := io.ReadFull(sys.FdReader(fd), (*[unsafe.Sizeof(v)]byte)(unsafe.Pointer(&v))[:])
+		t.AssertNoError(err)
+		return &fdevents.Event{RisingEdge: v%2 == 0, Time: time.Unix(v, 0)}, nil
+	}, 4, fdevents.OverflowBlock)
+	t.AssertNoError(err)
+
+	rising := func(e *fdevents.Event) bool { return e.RisingEdge }
+	e, err := events.Wait(context.Background(), rising)
+	t.AssertNoError(err)
+	t.AssertEqual(e.Time.Unix(), int64(2))
+	e, err = events.Wait(context.Background(), rising)
+	t.AssertNoError(err)
+	t.AssertEqual(e.Time.Unix(), int64(4))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	_, err = events.Wait(ctx, rising)
+	t.AssertEqual(err, context.DeadlineExceeded)
+
+	t.AssertNoError(events.Close())
+	_, err = events.Wait(context.Background(), rising)
+	t.AssertEqual(err, fdevents.ErrClosed)
+}
+
+func TestWatcherError(t1 *testing.T) {
+	t := NewTB(t1)
+
+	var pipe [2]int
+	t.AssertNoError(unix.Pipe(pipe[:]))
+	defer unix.Close(pipe[1])
+
+	var readErr = errors.New("read error")
+	var exitErr = make(chan error, 1)
+	watcher, err := fdevents.Watch(pipe[0], true /*close fd on close*/, unix.EPOLLIN, func(fd int) error {
+		return readErr
+	}, func(err error) {
+		exitErr <- err
+	})
+	t.AssertNoError(err)
+
+	var v int64 = 1
+	_, err = unix.Write(pipe[1], (*[unsafe.Sizeof(v)]byte)(unsafe.Pointer(&v))[:])
+	t.AssertNoError(err)
+	t.AssertEqual(<-exitErr, readErr)
+	// Close after the loop exited.
+	t.AssertNoError(watcher.Close())
+}
+
+func TestFdEventsError(t1 *testing.T) {
+	t := NewTB(t1)
+
+	var pipe [2]int
+	t.AssertNoError(unix.Pipe(pipe[:]))
+	defer unix.Close(pipe[1])
+
+	var readErr = errors.New("read error")
+	events, err := fdevents.New(pipe[0], true /*close fd on close*/, unix.EPOLLIN, func(fd int) (*fdevents.Event, error) {
+		var v int64
+		_, err := io.ReadFull(sys.FdReader(fd), (*[unsafe.Sizeof(v)]byte)(unsafe.Pointer(&v))[:])
+		t.AssertNoError(err)
+		if v == 2 {
+			return nil, readErr
+		}
+		return &fdevents.Event{Time: time.Unix(v, 0)}, nil
+	})
+	t.AssertNoError(err)
+
+	for v := int64(1); v <= 2; v++ {
+		_, err := unix.Write(pipe[1], (*[unsafe.Sizeof(v)]byte)(unsafe.Pointer(&v))[:])
+		t.AssertNoError(err)
+	}
+	var received []int64
+	for e := range events.Events() {
+		received = append(received, e.Time.Unix())
+	}
+	t.AssertEqualSlice(received, []int64{1})
+	t.AssertEqual(events.Err(), readErr)
+	t.AssertNoError(events.Close())
+}
@@ -0,0 +1,201 @@
+package fdevents
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// poller is the epoll_wait loop shared by all the Watchers in the process.
+// Only the loop goroutine blocks in epoll_wait, so watching more fds does not
+// tie up more OS threads.
+//
+// Fds are registered with EPOLLONESHOT. When a fd is ready, the loop wakes up
+// the goroutine of its Watcher, which handles the fd and then re-arms it.
+// So a slow or blocking Watcher never delays the others.
+type poller struct {
+	epollFd  int
+	lock     sync.Mutex
+	watchers map[int32]*Watcher // Keyed by the id in epoll_event data.
+	nextID   int32
+	// Closed when the loop exits because of err.
+	dead chan struct{}
+	err  error
+}
+
+var (
+	thePoller     *poller
+	thePollerErr  error
+	thePollerOnce sync.Once
+)
+
+// sharedPoller returns the poller of the process, starting it if not started yet.
+func sharedPoller() (*poller, error) {
+	thePollerOnce.Do(func() {
+		epollFd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+		if err != nil {
+			thePollerErr = fmt.Errorf("epoll_create: %w", err)
+			return
+		}
+		thePoller = &poller{
+			epollFd:  epollFd,
+			watchers: make(map[int32]*Watcher),
+			dead:     make(chan struct{}),
+		}
+		go thePoller.loop()
+	})
+	return thePoller, thePollerErr
+}
+
+func (p *poller) loop() {
+	var waitEvents [16]unix.EpollEvent
+	for {
+		n, err := unix.EpollWait(p.epollFd, waitEvents[:], -1)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			p.err = fmt.Errorf("failed to call epoll_wait: %w", err)
+			close(p.dead)
+			return
+		}
+		p.lock.Lock()
+		for i := 0; i < n; i++ {
+			if watcher := p.watchers[waitEvents[i].Fd]; watcher != nil {
+				select {
+				case watcher.ready <- struct{}{}:
+				default: // Already notified.
+				}
+			}
+		}
+		p.lock.Unlock()
+	}
+}
+
+// add adds watcher and returns its id.
+func (p *poller) add(watcher *Watcher) (id int32) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for {
+		p.nextID++
+		if _, ok := p.watchers[p.nextID]; !ok {
+			break
+		}
+	}
+	p.watchers[p.nextID] = watcher
+	return p.nextID
+}
+
+func (p *poller) remove(id int32) {
+	p.lock.Lock()
+	delete(p.watchers, id)
+	p.lock.Unlock()
+}
+
+// Watcher calls a function whenever a fd is ready.
+type Watcher struct {
+	ready     chan struct{} // Sent by the poller when the fd is ready.
+	quit      chan struct{} // Closed by Close.
+	done      sync.WaitGroup
+	closeLock sync.Mutex
+	closed    bool
+}
+
+// Watch creates a Watcher and returns any error encountered.
+// The returned Watcher waits fd for fdEpollEvents in the epoll_wait loop shared
+// by the process. If fd is ready, onReady is called with fd in a goroutine of
+// the Watcher. If onReady returns an error, the Watcher stops.
+// When the Watcher stops, onExit is called in the same goroutine after fd is closed
+// if closeFdOnClose is true. The error passed to onExit is the error that caused
+// the Watcher to stop, or nil if it is stopped by Close.
+func Watch(fd int, closeFdOnClose bool, fdEpollEvents uint32, onReady func(fd int) error, onExit func(err error)) (watcher *Watcher, err error) {
+	p, err := sharedPoller()
+	if err != nil {
+		err = fmt.Errorf("request GPIO event failed: %w", err)
+		return
+	}
+	select {
+	case <-p.dead:
+		err = fmt.Errorf("request GPIO event failed: %w", p.err)
+		return
+	default:
+	}
+
+	watcher = &Watcher{
+		ready: make(chan struct{}, 1),
+		quit:  make(chan struct{}),
+	}
+	id := p.add(watcher)
+	err = unix.EpollCtl(p.epollFd, unix.EPOLL_CTL_ADD, fd, &unix.EpollEvent{
+		Events: fdEpollEvents | unix.EPOLLONESHOT,
+		Fd:     id,
+	})
+	if err != nil {
+		p.remove(id)
+		watcher = nil
+		err = fmt.Errorf("request GPIO event failed: epoll_ctl %w", err)
+		return
+	}
+
+	watcher.done.Add(1)
+	go watcher.run(p, id, fd, closeFdOnClose, fdEpollEvents, onReady, onExit)
+	return
+}
+
+func (watcher *Watcher) run(p *poller, id int32, fd int, closeFdOnClose bool, fdEpollEvents uint32, onReady func(fd int) error, onExit func(err error)) {
+	var err error
+	defer func() {
+		if err1 := unix.EpollCtl(p.epollFd, unix.EPOLL_CTL_DEL, fd, nil); err1 != nil && err == nil {
+			err = fmt.Errorf("failed to call epoll_ctl: %w", err1)
+		}
+		p.remove(id)
+		if closeFdOnClose {
+			if err1 := unix.Close(fd); err1 != nil && err == nil {
+				err = fmt.Errorf("failed to call close: %w", err1)
+			}
+		}
+		onExit(err)
+		watcher.done.Done()
+	}()
+
+	for {
+		select {
+		case <-watcher.ready:
+			if err = onReady(fd); err != nil {
+				return
+			}
+			// Re-arm the one-shot fd.
+			err = unix.EpollCtl(p.epollFd, unix.EPOLL_CTL_MOD, fd, &unix.EpollEvent{
+				Events: fdEpollEvents | unix.EPOLLONESHOT,
+				Fd:     id,
+			})
+			if err != nil {
+				err = fmt.Errorf("failed to call epoll_ctl: %w", err)
+				return
+			}
+		case <-watcher.quit:
+			return
+		case <-p.dead:
+			err = p.err
+			return
+		}
+	}
+}
+
+// Close stops the Watcher and close the fd if required.
+// Close waits for the Watcher to stop. It is OK if the Watcher has stopped
+// because of an error. It is safe to call Close concurrently, and Close returns
+// ErrClosed if already closed.
+func (watcher *Watcher) Close() (err error) {
+	watcher.closeLock.Lock()
+	defer watcher.closeLock.Unlock()
+	if watcher.closed {
+		return ErrClosed
+	}
+	watcher.closed = true
+	close(watcher.quit)
+	watcher.done.Wait()
+	return
+}
@@ -0,0 +1,236 @@
+package fdevents_test
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+
+	. "github.com/mkch/asserting"
+	"github.com/mkch/gpio/internal/fdevents"
+	"github.com/mkch/gpio/internal/sys"
+	"golang.org/x/sys/unix"
+)
+
+func readInt64(fd int) (v int64, err error) {
+	_, err = io.ReadFull(sys.FdReader(fd), (*[unsafe.Sizeof(v)]byte)(unsafe.Pointer(&v))[:])
+	return
+}
+
+func writeInt64(fd int, v int64) (err error) {
+	_, err = unix.Write(fd, (*[unsafe.Sizeof(v)]byte)(unsafe.Pointer(&v))[:])
+	return
+}
+
+// A blocking Watcher must not delay the others.
+func TestWatcherIndependent(t1 *testing.T) {
+	t := NewTB(t1)
+
+	var pipe1, pipe2 [2]int
+	t.AssertNoError(unix.Pipe(pipe1[:]))
+	defer unix.Close(pipe1[1])
+	t.AssertNoError(unix.Pipe(pipe2[:]))
+	defer unix.Close(pipe2[1])
+
+	var unblock = make(chan struct{})
+	blocking, err := fdevents.Watch(pipe1[0], true /*close fd on close*/, unix.EPOLLIN, func(fd int) error {
+		_, err := readInt64(fd)
+		<-unblock
+		return err
+	}, func(err error) {})
+	t.AssertNoError(err)
+	defer func() { t.AssertNoError(blocking.Close()) }()
+	defer close(unblock)
+
+	var ready = make(chan int64, 1)
+	watcher, err := fdevents.Watch(pipe2[0], true /*close fd on close*/, unix.EPOLLIN, func(fd int) error {
+		v, err := readInt64(fd)
+		ready <- v
+		return err
+	}, func(err error) {})
+	t.AssertNoError(err)
+	defer func() { t.AssertNoError(watcher.Close()) }()
+
+	t.AssertNoError(writeInt64(pipe1[1], 1))
+	for i := int64(1); i <= 3; i++ {
+		t.AssertNoError(writeInt64(pipe2[1], i))
+		select {
+		case v := <-ready:
+			t.AssertEqual(v, i)
+		case <-time.After(time.Second * 5):
+			t1.Fatal("blocked by another watcher")
+		}
+	}
+}
+
+func TestWatcherCloseConcurrently(t1 *testing.T) {
+	t := NewTB(t1)
+
+	var pipe [2]int
+	t.AssertNoError(unix.Pipe(pipe[:]))
+	defer unix.Close(pipe[1])
+
+	var exits int
+	watcher, err := fdevents.Watch(pipe[0], true /*close fd on close*/, unix.EPOLLIN, func(fd int) error {
+		_, err := readInt64(fd)
+		return err
+	}, func(err error) { exits++ })
+	t.AssertNoError(err)
+
+	var wg sync.WaitGroup
+	var errs = make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- watcher.Close()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	var closed int
+	for err := range errs {
+		if err == nil {
+			closed++
+		} else {
+			t.AssertEqual(err, fdevents.ErrClosed)
+		}
+	}
+	t.AssertEqual(closed, 1)
+	t.AssertEqual(exits, 1)
+}
+
+// threads returns the number of OS threads of the process.
+func threads() int {
+	status, err := os.Open("/proc/self/status")
+	if err != nil {
+		return -1
+	}
+	defer status.Close()
+	scanner := bufio.NewScanner(status)
+	for scanner.Scan() {
+		if line := scanner.Bytes(); bytes.HasPrefix(line, []byte("Threads:")) {
+			n, err := strconv.Atoi(string(bytes.TrimSpace(line[len("Threads:"):])))
+			if err != nil {
+				return -1
+			}
+			return n
+		}
+	}
+	return -1
+}
+
+// watchFunc watches fd and calls onReady when fd is ready, until stop is called.
+type watchFunc func(fd int, onReady func(fd int)) (stop func(), err error)
+
+// sharedWatch watches fd with the shared epoll_wait loop of fdevents.
+func sharedWatch(fd int, onReady func(fd int)) (stop func(), err error) {
+	watcher, err := fdevents.Watch(fd, false, unix.EPOLLIN, func(fd int) error {
+		onReady(fd)
+		return nil
+	}, func(err error) {})
+	if err != nil {
+		return
+	}
+	return func() { watcher.Close() }, nil
+}
+
+// dedicatedWatch watches fd with an epoll instance and a goroutine blocking in
+// epoll_wait dedicated to fd, the way fdevents used to do, for comparison.
+func dedicatedWatch(fd int, onReady func(fd int)) (stop func(), err error) {
+	epollFd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		return
+	}
+	wakeUpFd, err := unix.Eventfd(0, unix.EFD_CLOEXEC)
+	if err != nil {
+		unix.Close(epollFd)
+		return
+	}
+	for _, f := range []int{fd, wakeUpFd} {
+		if err = unix.EpollCtl(epollFd, unix.EPOLL_CTL_ADD, f, &unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(f)}); err != nil {
+			unix.Close(epollFd)
+			unix.Close(wakeUpFd)
+			return
+		}
+	}
+	var done sync.WaitGroup
+	done.Add(1)
+	go func() {
+		defer done.Done()
+		var waitEvents [2]unix.EpollEvent
+		for {
+			n, err := unix.EpollWait(epollFd, waitEvents[:], -1)
+			if err != nil {
+				if err == unix.EINTR {
+					continue
+				}
+				return
+			}
+			for i := 0; i < n; i++ {
+				if waitEvents[i].Fd == int32(wakeUpFd) {
+					return
+				}
+				onReady(fd)
+			}
+		}
+	}()
+	return func() {
+		writeInt64(wakeUpFd, 1)
+		done.Wait()
+		unix.Close(epollFd)
+		unix.Close(wakeUpFd)
+	}, nil
+}
+
+// benchmarkWatch watches 40 pipes with watch, reports the number of OS threads,
+// and measures the latency from writing a pipe to receiving the value.
+func benchmarkWatch(b *testing.B, watch watchFunc) {
+	const numPipes = 40
+	var received = make(chan int64)
+	var writeFds []int
+	for i := 0; i < numPipes; i++ {
+		var pipe [2]int
+		if err := unix.Pipe(pipe[:]); err != nil {
+			b.Fatal(err)
+		}
+		defer unix.Close(pipe[0])
+		defer unix.Close(pipe[1])
+		stop, err := watch(pipe[0], func(fd int) {
+			v, err := readInt64(fd)
+			if err != nil {
+				b.Error(err)
+			}
+			received <- v
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer stop()
+		writeFds = append(writeFds, pipe[1])
+	}
+	// Wait for the goroutines to block in epoll_wait.
+	time.Sleep(time.Millisecond * 100)
+	var numThreads = threads()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := writeInt64(writeFds[i%numPipes], int64(i)); err != nil {
+			b.Fatal(err)
+		}
+		if v := <-received; v != int64(i) {
+			b.Fatalf("received %v, want %v", v, i)
+		}
+	}
+	b.ReportMetric(float64(numThreads), "threads")
+}
+
+func BenchmarkWatch(b *testing.B) {
+	b.Run("shared", func(b *testing.B) { benchmarkWatch(b, sharedWatch) })
+	b.Run("dedicated", func(b *testing.B) { benchmarkWatch(b, dedicatedWatch) })
+}
@@ -0,0 +1,22 @@
+// Package sim connects simulated GPIO chip devices, such as the ones of package gpiotest, to package gpio.
+package sim
+
+import "unsafe"
+
+// Device is a simulated GPIO chip device.
+// The fds of the device are real file descriptors, which can be polled for
+// GPIO events and line info changes, but the ioctl calls on them are handled
+// by the device instead of the kernel.
+type Device interface {
+	// Open opens the device and returns the chip fd.
+	Open() (fd int, err error)
+	// Ioctl handles the ioctl request on fd, a chip fd or a line request fd.
+	Ioctl(fd int, request uintptr, arg unsafe.Pointer) error
+	// Close closes fd.
+	Close(fd int) error
+}
+
+// OpenChip opens dev as a *gpio.Chip with name, without looking up the chip
+// devices, so the simulated chips never collide with the real ones.
+// It is set by package gpio, which can't be imported here.
+var OpenChip func(name string, dev Device) (chip interface{}, err error)
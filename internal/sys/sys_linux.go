@@ -2,6 +2,9 @@
 package sys
 
 import (
+	"io"
+	"unsafe"
+
 	"golang.org/x/sys/unix"
 )
 
@@ -14,6 +17,16 @@ const (
 	GPIOHANDLE_SET_LINE_VALUES_IOCTL = 0xc040b409
 	GPIOHANDLE_GET_LINE_VALUES_IOCTL = 0xc040b408
 	GPIO_GET_LINEEVENT_IOCTL         = 0xc030b404
+	GPIOHANDLE_SET_CONFIG_IOCTL      = 0xc054b40a // Linux 5.5+
+	GPIO_GET_LINEINFO_WATCH_IOCTL    = 0xc048b40b // Linux 5.7+
+	GPIO_GET_LINEINFO_UNWATCH_IOCTL  = 0xc004b40c // Linux 5.7+
+
+	GPIO_V2_GET_LINEINFO_IOCTL       = 0xc100b405
+	GPIO_V2_GET_LINEINFO_WATCH_IOCTL = 0xc100b406
+	GPIO_V2_GET_LINE_IOCTL           = 0xc250b407
+	GPIO_V2_LINE_SET_CONFIG_IOCTL    = 0xc110b40d
+	GPIO_V2_LINE_GET_VALUES_IOCTL    = 0xc010b40e
+	GPIO_V2_LINE_SET_VALUES_IOCTL    = 0xc010b40f
 )
 
 // gpiochip_info
@@ -29,6 +42,10 @@ const (
 	GPIOLINE_FLAG_ACTIVE_LOW  = 1 << 2
 	GPIOLINE_FLAG_OPEN_DRAIN  = 1 << 3
 	GPIOLINE_FLAG_OPEN_SOURCE = 1 << 4
+	// Linux 5.5+
+	GPIOLINE_FLAG_BIAS_PULL_UP   = 1 << 5
+	GPIOLINE_FLAG_BIAS_PULL_DOWN = 1 << 6
+	GPIOLINE_FLAG_BIAS_DISABLE   = 1 << 7
 )
 
 // gpioline_info
@@ -39,6 +56,21 @@ type GPIOLineInfo struct {
 	Consumer   [32]byte
 }
 
+// Possible line status change events.
+const (
+	GPIOLINE_CHANGED_REQUESTED = 1
+	GPIOLINE_CHANGED_RELEASED  = 2
+	GPIOLINE_CHANGED_CONFIG    = 3
+)
+
+// gpioline_info_changed
+type GPIOLineInfoChanged struct {
+	Info      GPIOLineInfo
+	Timestamp uint64
+	EventType uint32
+	padding   [5]uint32
+}
+
 //https://www.kernel.org/doc/Documentation/gpio/gpio.txt
 // https://embeddedartistry.com/blog/2018/6/4/demystifying-microcontroller-gpio-settings#open-drain-output
 const (
@@ -47,6 +79,10 @@ const (
 	GPIOHANDLE_REQUEST_ACTIVE_LOW  = 1 << 2
 	GPIOHANDLE_REQUEST_OPEN_DRAIN  = 1 << 3
 	GPIOHANDLE_REQUEST_OPEN_SOURCE = 1 << 4
+	// Linux 5.5+
+	GPIOHANDLE_REQUEST_BIAS_PULL_UP   = 1 << 5
+	GPIOHANDLE_REQUEST_BIAS_PULL_DOWN = 1 << 6
+	GPIOHANDLE_REQUEST_BIAS_DISABLE   = 1 << 7
 )
 
 //gpiohandle_request
@@ -59,6 +95,13 @@ type GPIOHandleRequest struct {
 	Fd            int32
 }
 
+// gpiohandle_config
+type GPIOHandleConfig struct {
+	Flags         uint32
+	DefaultValues [64]byte
+	padding       [4]uint32
+}
+
 const (
 	GPIOEVENT_REQUEST_RISING_EDGE  = 1 << 0
 	GPIOEVENT_REQUEST_FALLING_EDGE = 1 << 1
@@ -86,6 +129,149 @@ type GPIOEventData struct {
 	pad       [4]byte
 }
 
+// GPIO_V2_LINES_MAX is the maximum number of requested lines of uAPI v2.
+const GPIO_V2_LINES_MAX = 64
+
+// GPIO_V2_LINE_NUM_ATTRS_MAX is the maximum number of configuration attributes
+// associated with a line request.
+const GPIO_V2_LINE_NUM_ATTRS_MAX = 10
+
+// gpio_v2_line_flag
+const (
+	GPIO_V2_LINE_FLAG_USED                 = 1 << 0
+	GPIO_V2_LINE_FLAG_ACTIVE_LOW           = 1 << 1
+	GPIO_V2_LINE_FLAG_INPUT                = 1 << 2
+	GPIO_V2_LINE_FLAG_OUTPUT               = 1 << 3
+	GPIO_V2_LINE_FLAG_EDGE_RISING          = 1 << 4
+	GPIO_V2_LINE_FLAG_EDGE_FALLING         = 1 << 5
+	GPIO_V2_LINE_FLAG_OPEN_DRAIN           = 1 << 6
+	GPIO_V2_LINE_FLAG_OPEN_SOURCE          = 1 << 7
+	GPIO_V2_LINE_FLAG_BIAS_PULL_UP         = 1 << 8
+	GPIO_V2_LINE_FLAG_BIAS_PULL_DOWN       = 1 << 9
+	GPIO_V2_LINE_FLAG_BIAS_DISABLED        = 1 << 10
+	GPIO_V2_LINE_FLAG_EVENT_CLOCK_REALTIME = 1 << 11
+	GPIO_V2_LINE_FLAG_EVENT_CLOCK_HTE      = 1 << 12
+)
+
+// gpio_v2_line_values
+type GPIOV2LineValues struct {
+	Bits uint64
+	Mask uint64
+}
+
+// gpio_v2_line_attr_id
+const (
+	GPIO_V2_LINE_ATTR_ID_FLAGS         = 1
+	GPIO_V2_LINE_ATTR_ID_OUTPUT_VALUES = 2
+	GPIO_V2_LINE_ATTR_ID_DEBOUNCE      = 3
+)
+
+// gpio_v2_line_attribute
+type GPIOV2LineAttribute struct {
+	ID      uint32
+	padding uint32
+	// union {flags, values, debounce_period_us}
+	Value [8]byte
+}
+
+// Flags returns the flags field of the union.
+func (attr *GPIOV2LineAttribute) Flags() uint64 {
+	return *(*uint64)(unsafe.Pointer(&attr.Value[0]))
+}
+
+// SetFlags sets the flags field of the union.
+func (attr *GPIOV2LineAttribute) SetFlags(flags uint64) {
+	*(*uint64)(unsafe.Pointer(&attr.Value[0])) = flags
+}
+
+// Values returns the values field of the union.
+func (attr *GPIOV2LineAttribute) Values() uint64 {
+	return attr.Flags()
+}
+
+// SetValues sets the values field of the union.
+func (attr *GPIOV2LineAttribute) SetValues(values uint64) {
+	attr.SetFlags(values)
+}
+
+// DebouncePeriodUs returns the debounce_period_us field of the union.
+func (attr *GPIOV2LineAttribute) DebouncePeriodUs() uint32 {
+	return *(*uint32)(unsafe.Pointer(&attr.Value[0]))
+}
+
+// SetDebouncePeriodUs sets the debounce_period_us field of the union.
+func (attr *GPIOV2LineAttribute) SetDebouncePeriodUs(us uint32) {
+	attr.Value = [8]byte{}
+	*(*uint32)(unsafe.Pointer(&attr.Value[0])) = us
+}
+
+// gpio_v2_line_config_attribute
+type GPIOV2LineConfigAttribute struct {
+	Attr GPIOV2LineAttribute
+	Mask uint64
+}
+
+// gpio_v2_line_config
+type GPIOV2LineConfig struct {
+	Flags    uint64
+	NumAttrs uint32
+	padding  [5]uint32
+	Attrs    [GPIO_V2_LINE_NUM_ATTRS_MAX]GPIOV2LineConfigAttribute
+}
+
+// gpio_v2_line_request
+type GPIOV2LineRequest struct {
+	Offsets         [GPIO_V2_LINES_MAX]uint32
+	Consumer        [32]byte
+	Config          GPIOV2LineConfig
+	NumLines        uint32
+	EventBufferSize uint32
+	padding         [5]uint32
+	Fd              int32
+}
+
+// gpio_v2_line_info
+type GPIOV2LineInfo struct {
+	Name     [32]byte
+	Consumer [32]byte
+	Offset   uint32
+	NumAttrs uint32
+	Flags    uint64
+	Attrs    [GPIO_V2_LINE_NUM_ATTRS_MAX]GPIOV2LineAttribute
+	padding  [4]uint32
+}
+
+// gpio_v2_line_changed_type
+const (
+	GPIO_V2_LINE_CHANGED_REQUESTED = 1
+	GPIO_V2_LINE_CHANGED_RELEASED  = 2
+	GPIO_V2_LINE_CHANGED_CONFIG    = 3
+)
+
+// gpio_v2_line_info_changed
+type GPIOV2LineInfoChanged struct {
+	Info        GPIOV2LineInfo
+	TimestampNs uint64
+	EventType   uint32
+	padding     [5]uint32
+}
+
+// gpio_v2_line_event_id
+const (
+	GPIO_V2_LINE_EVENT_RISING_EDGE  = 1
+	GPIO_V2_LINE_EVENT_FALLING_EDGE = 2
+)
+
+// gpio_v2_line_event
+type GPIOV2LineEvent struct {
+	TimestampNs uint64
+	ID          uint32
+	Offset      uint32
+	Seqno       uint32
+	LineSeqno   uint32
+	padding     [6]uint32
+}
+
 // Ioctl call ioctl with one argument and no return value.
 func Ioctl(fd int, request uintptr, a uintptr) error {
 	_, _, err := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(request), a)
@@ -99,5 +285,10 @@ func Ioctl(fd int, request uintptr, a uintptr) error {
 type FdReader int
 
 func (fd FdReader) Read(p []byte) (n int, err error) {
-	return unix.Read(int(fd), p)
+	n, err = unix.Read(int(fd), p)
+	if n == 0 && err == nil && len(p) > 0 {
+		// The other end is closed.
+		err = io.EOF
+	}
+	return
 }
@@ -46,6 +46,51 @@ func TestConsts(t *testing.T) {
 			GPIO_GET_LINEEVENT_IOCTL,
 			c.GPIO_GET_LINEEVENT_IOCTL,
 		},
+		testCase{
+			"GPIOHANDLE_SET_CONFIG_IOCTL",
+			GPIOHANDLE_SET_CONFIG_IOCTL,
+			c.GPIOHANDLE_SET_CONFIG_IOCTL,
+		},
+		testCase{
+			"GPIO_GET_LINEINFO_WATCH_IOCTL",
+			GPIO_GET_LINEINFO_WATCH_IOCTL,
+			c.GPIO_GET_LINEINFO_WATCH_IOCTL,
+		},
+		testCase{
+			"GPIO_GET_LINEINFO_UNWATCH_IOCTL",
+			GPIO_GET_LINEINFO_UNWATCH_IOCTL,
+			c.GPIO_GET_LINEINFO_UNWATCH_IOCTL,
+		},
+		testCase{
+			"GPIO_V2_GET_LINEINFO_IOCTL",
+			GPIO_V2_GET_LINEINFO_IOCTL,
+			c.GPIO_V2_GET_LINEINFO_IOCTL,
+		},
+		testCase{
+			"GPIO_V2_GET_LINEINFO_WATCH_IOCTL",
+			GPIO_V2_GET_LINEINFO_WATCH_IOCTL,
+			c.GPIO_V2_GET_LINEINFO_WATCH_IOCTL,
+		},
+		testCase{
+			"GPIO_V2_GET_LINE_IOCTL",
+			GPIO_V2_GET_LINE_IOCTL,
+			c.GPIO_V2_GET_LINE_IOCTL,
+		},
+		testCase{
+			"GPIO_V2_LINE_SET_CONFIG_IOCTL",
+			GPIO_V2_LINE_SET_CONFIG_IOCTL,
+			c.GPIO_V2_LINE_SET_CONFIG_IOCTL,
+		},
+		testCase{
+			"GPIO_V2_LINE_GET_VALUES_IOCTL",
+			GPIO_V2_LINE_GET_VALUES_IOCTL,
+			c.GPIO_V2_LINE_GET_VALUES_IOCTL,
+		},
+		testCase{
+			"GPIO_V2_LINE_SET_VALUES_IOCTL",
+			GPIO_V2_LINE_SET_VALUES_IOCTL,
+			c.GPIO_V2_LINE_SET_VALUES_IOCTL,
+		},
 		testCase{
 			"GPIOLINE_FLAG_KERNEL",
 			GPIOLINE_FLAG_KERNEL,
@@ -71,6 +116,36 @@ func TestConsts(t *testing.T) {
 			GPIOLINE_FLAG_OPEN_SOURCE,
 			c.GPIOLINE_FLAG_OPEN_SOURCE,
 		},
+		testCase{
+			"GPIOLINE_FLAG_BIAS_PULL_UP",
+			GPIOLINE_FLAG_BIAS_PULL_UP,
+			c.GPIOLINE_FLAG_BIAS_PULL_UP,
+		},
+		testCase{
+			"GPIOLINE_FLAG_BIAS_PULL_DOWN",
+			GPIOLINE_FLAG_BIAS_PULL_DOWN,
+			c.GPIOLINE_FLAG_BIAS_PULL_DOWN,
+		},
+		testCase{
+			"GPIOLINE_FLAG_BIAS_DISABLE",
+			GPIOLINE_FLAG_BIAS_DISABLE,
+			c.GPIOLINE_FLAG_BIAS_DISABLE,
+		},
+		testCase{
+			"GPIOLINE_CHANGED_REQUESTED",
+			GPIOLINE_CHANGED_REQUESTED,
+			c.GPIOLINE_CHANGED_REQUESTED,
+		},
+		testCase{
+			"GPIOLINE_CHANGED_RELEASED",
+			GPIOLINE_CHANGED_RELEASED,
+			c.GPIOLINE_CHANGED_RELEASED,
+		},
+		testCase{
+			"GPIOLINE_CHANGED_CONFIG",
+			GPIOLINE_CHANGED_CONFIG,
+			c.GPIOLINE_CHANGED_CONFIG,
+		},
 		testCase{
 			"GPIOHANDLE_REQUEST_INPUT",
 			GPIOHANDLE_REQUEST_INPUT,
@@ -96,6 +171,21 @@ func TestConsts(t *testing.T) {
 			GPIOHANDLE_REQUEST_OPEN_SOURCE,
 			c.GPIOHANDLE_REQUEST_OPEN_SOURCE,
 		},
+		testCase{
+			"GPIOHANDLE_REQUEST_BIAS_PULL_UP",
+			GPIOHANDLE_REQUEST_BIAS_PULL_UP,
+			c.GPIOHANDLE_REQUEST_BIAS_PULL_UP,
+		},
+		testCase{
+			"GPIOHANDLE_REQUEST_BIAS_PULL_DOWN",
+			GPIOHANDLE_REQUEST_BIAS_PULL_DOWN,
+			c.GPIOHANDLE_REQUEST_BIAS_PULL_DOWN,
+		},
+		testCase{
+			"GPIOHANDLE_REQUEST_BIAS_DISABLE",
+			GPIOHANDLE_REQUEST_BIAS_DISABLE,
+			c.GPIOHANDLE_REQUEST_BIAS_DISABLE,
+		},
 		testCase{
 			"GPIOEVENT_REQUEST_RISING_EDGE",
 			GPIOEVENT_REQUEST_RISING_EDGE,
@@ -120,6 +210,45 @@ func TestConsts(t *testing.T) {
 	}
 }
 
+func TestConstsV2(t *testing.T) {
+	type testCase struct {
+		name string
+		arg  uint64
+		want uint64
+	}
+	tests := []testCase{
+		testCase{"GPIO_V2_LINES_MAX", GPIO_V2_LINES_MAX, c.GPIO_V2_LINES_MAX},
+		testCase{"GPIO_V2_LINE_NUM_ATTRS_MAX", GPIO_V2_LINE_NUM_ATTRS_MAX, c.GPIO_V2_LINE_NUM_ATTRS_MAX},
+		testCase{"GPIO_V2_LINE_FLAG_USED", GPIO_V2_LINE_FLAG_USED, c.GPIO_V2_LINE_FLAG_USED},
+		testCase{"GPIO_V2_LINE_FLAG_ACTIVE_LOW", GPIO_V2_LINE_FLAG_ACTIVE_LOW, c.GPIO_V2_LINE_FLAG_ACTIVE_LOW},
+		testCase{"GPIO_V2_LINE_FLAG_INPUT", GPIO_V2_LINE_FLAG_INPUT, c.GPIO_V2_LINE_FLAG_INPUT},
+		testCase{"GPIO_V2_LINE_FLAG_OUTPUT", GPIO_V2_LINE_FLAG_OUTPUT, c.GPIO_V2_LINE_FLAG_OUTPUT},
+		testCase{"GPIO_V2_LINE_FLAG_EDGE_RISING", GPIO_V2_LINE_FLAG_EDGE_RISING, c.GPIO_V2_LINE_FLAG_EDGE_RISING},
+		testCase{"GPIO_V2_LINE_FLAG_EDGE_FALLING", GPIO_V2_LINE_FLAG_EDGE_FALLING, c.GPIO_V2_LINE_FLAG_EDGE_FALLING},
+		testCase{"GPIO_V2_LINE_FLAG_OPEN_DRAIN", GPIO_V2_LINE_FLAG_OPEN_DRAIN, c.GPIO_V2_LINE_FLAG_OPEN_DRAIN},
+		testCase{"GPIO_V2_LINE_FLAG_OPEN_SOURCE", GPIO_V2_LINE_FLAG_OPEN_SOURCE, c.GPIO_V2_LINE_FLAG_OPEN_SOURCE},
+		testCase{"GPIO_V2_LINE_FLAG_BIAS_PULL_UP", GPIO_V2_LINE_FLAG_BIAS_PULL_UP, c.GPIO_V2_LINE_FLAG_BIAS_PULL_UP},
+		testCase{"GPIO_V2_LINE_FLAG_BIAS_PULL_DOWN", GPIO_V2_LINE_FLAG_BIAS_PULL_DOWN, c.GPIO_V2_LINE_FLAG_BIAS_PULL_DOWN},
+		testCase{"GPIO_V2_LINE_FLAG_BIAS_DISABLED", GPIO_V2_LINE_FLAG_BIAS_DISABLED, c.GPIO_V2_LINE_FLAG_BIAS_DISABLED},
+		testCase{"GPIO_V2_LINE_FLAG_EVENT_CLOCK_REALTIME", GPIO_V2_LINE_FLAG_EVENT_CLOCK_REALTIME, c.GPIO_V2_LINE_FLAG_EVENT_CLOCK_REALTIME},
+		testCase{"GPIO_V2_LINE_FLAG_EVENT_CLOCK_HTE", GPIO_V2_LINE_FLAG_EVENT_CLOCK_HTE, c.GPIO_V2_LINE_FLAG_EVENT_CLOCK_HTE},
+		testCase{"GPIO_V2_LINE_ATTR_ID_FLAGS", GPIO_V2_LINE_ATTR_ID_FLAGS, c.GPIO_V2_LINE_ATTR_ID_FLAGS},
+		testCase{"GPIO_V2_LINE_ATTR_ID_OUTPUT_VALUES", GPIO_V2_LINE_ATTR_ID_OUTPUT_VALUES, c.GPIO_V2_LINE_ATTR_ID_OUTPUT_VALUES},
+		testCase{"GPIO_V2_LINE_ATTR_ID_DEBOUNCE", GPIO_V2_LINE_ATTR_ID_DEBOUNCE, c.GPIO_V2_LINE_ATTR_ID_DEBOUNCE},
+		testCase{"GPIO_V2_LINE_CHANGED_REQUESTED", GPIO_V2_LINE_CHANGED_REQUESTED, c.GPIO_V2_LINE_CHANGED_REQUESTED},
+		testCase{"GPIO_V2_LINE_CHANGED_RELEASED", GPIO_V2_LINE_CHANGED_RELEASED, c.GPIO_V2_LINE_CHANGED_RELEASED},
+		testCase{"GPIO_V2_LINE_CHANGED_CONFIG", GPIO_V2_LINE_CHANGED_CONFIG, c.GPIO_V2_LINE_CHANGED_CONFIG},
+		testCase{"GPIO_V2_LINE_EVENT_RISING_EDGE", GPIO_V2_LINE_EVENT_RISING_EDGE, c.GPIO_V2_LINE_EVENT_RISING_EDGE},
+		testCase{"GPIO_V2_LINE_EVENT_FALLING_EDGE", GPIO_V2_LINE_EVENT_FALLING_EDGE, c.GPIO_V2_LINE_EVENT_FALLING_EDGE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t1 *testing.T) {
+			t := NewTB(t1)
+			t.AssertEqual(tt.arg, tt.want)
+		})
+	}
+}
+
 func cmpType(t TB, t1, t2 reflect.Type) {
 	t.Helper()
 	if t1 == t2 {
@@ -164,7 +293,28 @@ func TestStruct(t1 *testing.T) {
 	t := NewTB(t1)
 	cmpType(t, reflect.TypeOf(GPIOChipInfo{}), reflect.TypeOf(c.GPIOChipInfo{}))
 	cmpType(t, reflect.TypeOf(GPIOLineInfo{}), reflect.TypeOf(c.GPIOLineInfo{}))
+	cmpType(t, reflect.TypeOf(GPIOLineInfoChanged{}), reflect.TypeOf(c.GPIOLineInfoChanged{}))
 	cmpType(t, reflect.TypeOf(GPIOHandleRequest{}), reflect.TypeOf(c.GPIOHandleRequest{}))
+	cmpType(t, reflect.TypeOf(GPIOHandleConfig{}), reflect.TypeOf(c.GPIOHandleConfig{}))
 	cmpType(t, reflect.TypeOf(GPIOEventRequest{}), reflect.TypeOf(c.GPIOEventRequest{}))
 	cmpType(t, reflect.TypeOf(GPIOEventData{}), reflect.TypeOf(c.GPIOEventData{}))
+	cmpType(t, reflect.TypeOf(GPIOV2LineValues{}), reflect.TypeOf(c.GPIOV2LineValues{}))
+	cmpType(t, reflect.TypeOf(GPIOV2LineAttribute{}), reflect.TypeOf(c.GPIOV2LineAttribute{}))
+	cmpType(t, reflect.TypeOf(GPIOV2LineConfigAttribute{}), reflect.TypeOf(c.GPIOV2LineConfigAttribute{}))
+	cmpType(t, reflect.TypeOf(GPIOV2LineConfig{}), reflect.TypeOf(c.GPIOV2LineConfig{}))
+	cmpType(t, reflect.TypeOf(GPIOV2LineRequest{}), reflect.TypeOf(c.GPIOV2LineRequest{}))
+	cmpType(t, reflect.TypeOf(GPIOV2LineInfo{}), reflect.TypeOf(c.GPIOV2LineInfo{}))
+	cmpType(t, reflect.TypeOf(GPIOV2LineInfoChanged{}), reflect.TypeOf(c.GPIOV2LineInfoChanged{}))
+	cmpType(t, reflect.TypeOf(GPIOV2LineEvent{}), reflect.TypeOf(c.GPIOV2LineEvent{}))
+}
+
+func TestAttributeUnion(t1 *testing.T) {
+	t := NewTB(t1)
+	var attr GPIOV2LineAttribute
+	attr.SetFlags(GPIO_V2_LINE_FLAG_INPUT | GPIO_V2_LINE_FLAG_BIAS_PULL_UP)
+	t.AssertEqual(attr.Flags(), uint64(GPIO_V2_LINE_FLAG_INPUT|GPIO_V2_LINE_FLAG_BIAS_PULL_UP))
+	attr.SetValues(1<<63 | 1)
+	t.AssertEqual(attr.Values(), uint64(1<<63|1))
+	attr.SetDebouncePeriodUs(5000)
+	t.AssertEqual(attr.DebouncePeriodUs(), uint32(5000))
 }
@@ -3,59 +3,202 @@ package gpio
 import (
 	"fmt"
 	"runtime"
+	"sync"
+	"time"
 	"unsafe"
 
+	"github.com/mkch/gpio/internal/sim"
 	"github.com/mkch/gpio/internal/sys"
-	"golang.org/x/sys/unix"
 )
 
 // Line is an opened GPIO line.
 type Line Lines
 
+// Close releases the GPIO line. See Lines.Close.
 func (l *Line) Close() (err error) {
 	return (*Lines)(l).Close()
 }
 
+// Config returns the configuration of the GPIO line. See Lines.Config.
+func (l *Line) Config() (config LineConfig) {
+	if configs := (*Lines)(l).Config(); len(configs) > 0 {
+		config = configs[0]
+	}
+	return
+}
+
 // Value returns the current value of the GPIO line. 1 (high) or 0 (low).
 func (l *Line) Value() (value byte, err error) {
-	values, err := (*Lines)(l).Values()
+	bits, err := (*Lines)(l).Bits()
 	if err != nil {
 		return
 	}
-	value = values[0]
+	value = byte(bits & 1)
 	return
 }
 
 // SetValue sets the value of the GPIO line.
 // Value should be 0 (low) or 1 (high), anything else than 0 will be interpreted as 1 (high).
 func (l *Line) SetValue(value byte) (err error) {
-	var values = [1]byte{value}
-	err = (*Lines)(l).SetValues(values[:])
-	runtime.KeepAlive(values)
-	return
+	var bits uint64
+	if value != 0 {
+		bits = 1
+	}
+	return (*Lines)(l).SetBits(bits)
+}
+
+// SetConfig changes the configuration of the GPIO line without releasing it.
+// See Lines.Reconfigure for details.
+func (l *Line) SetConfig(flags LineFlag, defaultValue byte, options ...LineOption) (err error) {
+	var defaultValues = [1]byte{defaultValue}
+	return (*Lines)(l).Reconfigure(flags, defaultValues[:], options...)
+}
+
+// SetInput configures the GPIO line as input, keeping the active-low, bias and
+// debounce configuration. Requires Linux 5.5+.
+func (l *Line) SetInput() error {
+	config := l.Config()
+	return l.SetConfig(config.Flags&^(Output|OpenDrain|OpenSource)|Input, 0, WithDebounce(config.Debounce))
+}
+
+// SetOutput configures the GPIO line as output and sets the value, keeping the
+// active-low, bias and drive configuration. Requires Linux 5.5+.
+// Value should be 0 (low) or 1 (high), anything else than 0 will be interpreted as 1 (high).
+func (l *Line) SetOutput(value byte) error {
+	config := l.Config()
+	return l.SetConfig(config.Flags&^Input|Output, value)
 }
 
 // Lines is a batch of opened GPIO lines.
+// It is safe to call the methods of Lines concurrently, including Close.
 type Lines struct {
-	fd       int
+	// Guards fd against being closed while in use.
+	lock     sync.RWMutex
+	fd       int // -1 if closed.
 	numLines int
+	offsets  []uint32   // The requested offsets.
+	v2       bool       // Whether fd is a uAPI v2 line request.
+	sim      sim.Device // The simulated device, nil if the lines are real.
+	// The configurations of the lines, guarded by lock.
+	configs []LineConfig
 }
 
+// LineConfig is the configuration of a requested GPIO line.
+type LineConfig struct {
+	Offset   uint32        // The offset of the line on the chip.
+	Flags    LineFlag      // Or'ed LineFlag values, such as Output, ActiveLow and PullUp.
+	Edges    EventFlag     // The edges to generate events on, 0 for none.
+	Debounce time.Duration // The debounce period, 0 if debouncing is disabled.
+}
+
+// lineConfigsOf returns the LineConfigs of the lines at offsets configured by configs.
+func lineConfigsOf(offsets []uint32, configs []lineConfig) []LineConfig {
+	var result = make([]LineConfig, len(configs))
+	for i := range configs {
+		result[i] = LineConfig{
+			Offset:   offsets[i],
+			Flags:    configs[i].flags,
+			Edges:    configs[i].edges,
+			Debounce: configs[i].debounce,
+		}
+	}
+	return result
+}
+
+// Offsets returns the offsets of the GPIO lines, in the order used to request them.
+func (l *Lines) Offsets() []uint32 {
+	return append([]uint32(nil), l.offsets...)
+}
+
+// Config returns the configurations of the GPIO lines in the order of Offsets,
+// as requested or last reconfigured.
+// The lines can be configured differently with ForOffset.
+func (l *Lines) Config() []LineConfig {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return append([]LineConfig(nil), l.configs...)
+}
+
+// setConfig sets the configurations returned by Config.
+func (l *Lines) setConfig(configs []lineConfig) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.configs = lineConfigsOf(l.offsets, configs)
+}
+
+// Close releases the GPIO lines.
+// Close returns ErrClosed if l is already closed, and so do the other methods.
 func (l *Lines) Close() (err error) {
-	err = unix.Close(l.fd)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.fd < 0 {
+		return ErrClosed
+	}
+	err = closeFd(l.sim, l.fd)
 	l.fd = -1
 	return
 }
 
+// ioctl calls ioctl on the fd of l, or returns ErrClosed if l is closed.
+func (l *Lines) ioctl(request uintptr, arg unsafe.Pointer) error {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	if l.fd < 0 {
+		return ErrClosed
+	}
+	return ioctl(l.sim, l.fd, request, arg)
+}
+
 // Values returns the current values of the GPIO lines. 1 (high) or 0 (low).
 func (l *Lines) Values() (values []byte, err error) {
+	values = make([]byte, l.numLines)
+	err = l.ValuesInto(values)
+	if err != nil {
+		values = nil
+	}
+	return
+}
+
+// ValuesInto stores the current values of the GPIO lines into dst, 1 (high) or 0 (low).
+// The length of dst must be at least the number of lines.
+// ValuesInto does not allocate, unless an error is returned.
+func (l *Lines) ValuesInto(dst []byte) (err error) {
+	if len(dst) < l.numLines {
+		err = fmt.Errorf("get GPIO line values failed: length of dst(%v) < %v", len(dst), l.numLines)
+		return
+	}
+	bits, err := l.Bits()
+	if err != nil {
+		return
+	}
+	bitsToValues(bits, dst[:l.numLines])
+	return
+}
+
+// Bits returns the current values of the GPIO lines as a bitmap.
+// Bit i stands for the ith line in the offsets used to open l,
+// set bit means 1 (high) and cleared bit means 0 (low).
+// Bits does not allocate, unless an error is returned.
+func (l *Lines) Bits() (bits uint64, err error) {
+	return l.ValuesMasked(lineMask(l.numLines))
+}
+
+// SetBits sets the values of the GPIO lines from a bitmap.
+// Bit i stands for the ith line in the offsets used to open l,
+// set bit means 1 (high) and cleared bit means 0 (low).
+// SetBits does not allocate, unless an error is returned.
+func (l *Lines) SetBits(bits uint64) (err error) {
+	if l.v2 {
+		return l.SetValuesMasked(lineMask(l.numLines), bits)
+	}
 	var arg [64]byte
-	err = sys.Ioctl(l.fd, sys.GPIOHANDLE_GET_LINE_VALUES_IOCTL, uintptr(unsafe.Pointer(&arg[0])))
+	bitsToValues(bits, arg[:l.numLines])
+	err = l.ioctl(sys.GPIOHANDLE_SET_LINE_VALUES_IOCTL, unsafe.Pointer(&arg[0]))
+	runtime.KeepAlive(arg)
 	if err != nil {
-		err = fmt.Errorf("get GPIO line values failed: %w", err)
+		err = fmt.Errorf("set GPIO line values failed: %w", err)
 		return
 	}
-	values = arg[:l.numLines]
 	return
 }
 
@@ -64,14 +207,153 @@ func (l *Lines) Values() (values []byte, err error) {
 func (l *Lines) SetValues(values []byte) (err error) {
 	if len(values) > 64 {
 		err = fmt.Errorf("set GPIO line values failed: length of values(%v) > 64", len(values))
+		return
+	}
+	if l.v2 {
+		var v2arg = sys.GPIOV2LineValues{Bits: valuesToBits(values), Mask: lineMask(l.numLines)}
+		err = l.ioctl(sys.GPIO_V2_LINE_SET_VALUES_IOCTL, unsafe.Pointer(&v2arg))
+	} else {
+		var arg [64]byte
+		copy(arg[:], values)
+		err = l.ioctl(sys.GPIOHANDLE_SET_LINE_VALUES_IOCTL, unsafe.Pointer(&arg[0]))
+		runtime.KeepAlive(arg)
+	}
+	if err != nil {
+		err = fmt.Errorf("set GPIO line values failed: %w", err)
+		return
 	}
+	return
+}
+
+// ValuesMasked returns the current values of the GPIO lines selected by mask as a bitmap.
+// Bit i of mask and bits stands for the ith line in the offsets used to open l.
+// The bits not selected by mask are 0.
+func (l *Lines) ValuesMasked(mask uint64) (bits uint64, err error) {
+	if err = l.checkMask(mask); err != nil {
+		err = fmt.Errorf("get GPIO line values failed: %w", err)
+		return
+	}
+	if l.v2 {
+		var arg = sys.GPIOV2LineValues{Mask: mask}
+		err = l.ioctl(sys.GPIO_V2_LINE_GET_VALUES_IOCTL, unsafe.Pointer(&arg))
+		if err != nil {
+			err = fmt.Errorf("get GPIO line values failed: %w", err)
+			return
+		}
+		bits = arg.Bits & mask
+		return
+	}
+	// Reading all the values is as good as reading some of them in uAPI v1.
 	var arg [64]byte
-	copy(arg[:], values)
-	err = sys.Ioctl(l.fd, sys.GPIOHANDLE_SET_LINE_VALUES_IOCTL, uintptr(unsafe.Pointer(&arg[0])))
-	runtime.KeepAlive(arg)
+	err = l.ioctl(sys.GPIOHANDLE_GET_LINE_VALUES_IOCTL, unsafe.Pointer(&arg[0]))
+	if err != nil {
+		err = fmt.Errorf("get GPIO line values failed: %w", err)
+		return
+	}
+	bits = valuesToBits(arg[:l.numLines]) & mask
+	return
+}
+
+// SetValuesMasked sets the values of the GPIO lines selected by mask in a
+// single operation, leaving the other lines unchanged.
+// Bit i of mask and bits stands for the ith line in the offsets used to open l.
+// Set bit means 1 (high) and cleared bit means 0 (low).
+// Requires uAPI v2(Linux 5.10+).
+func (l *Lines) SetValuesMasked(mask, bits uint64) (err error) {
+	if err = l.checkMask(mask); err != nil {
+		err = fmt.Errorf("set GPIO line values failed: %w", err)
+		return
+	}
+	if !l.v2 {
+		err = fmt.Errorf("set GPIO line values failed: %w: masked set requires GPIO uAPI v2(Linux 5.10+)", ErrUnsupported)
+		return
+	}
+	var arg = sys.GPIOV2LineValues{Bits: bits & mask, Mask: mask}
+	err = l.ioctl(sys.GPIO_V2_LINE_SET_VALUES_IOCTL, unsafe.Pointer(&arg))
 	if err != nil {
 		err = fmt.Errorf("set GPIO line values failed: %w", err)
 		return
 	}
 	return
 }
+
+// checkMask returns an error if mask selects no line or any line out of l.
+func (l *Lines) checkMask(mask uint64) error {
+	if mask == 0 || mask&^lineMask(l.numLines) != 0 {
+		return fmt.Errorf("invalid mask %#b of %v lines", mask, l.numLines)
+	}
+	return nil
+}
+
+// Reconfigure changes the direction, bias, drive and active-low configuration
+// of the GPIO lines in place, without releasing them. Requires Linux 5.5+.
+// Parameter flags is or'ed LineFlag values that will be applied to all the lines,
+// and replaces the flags used to open the lines.
+// Parameter defaultValues specifies the output values if Output is set in flags,
+// the same way as Chip.OpenLines.
+// Parameter options are optional configurations such as WithDebounce and ForOffset,
+// which are applied to flags and defaultValues, and also replace the ones used to
+// open the lines. WithConsumer can't be used to reconfigure lines.
+func (l *Lines) Reconfigure(flags LineFlag, defaultValues []byte, options ...LineOption) (err error) {
+	if len(defaultValues) > 64 {
+		err = fmt.Errorf("reconfigure GPIO lines failed: length of default values(%v) > 64", len(defaultValues))
+		return
+	}
+	opts, err := newRequestOptions(flags, defaultValues, "", 0, options)
+	if err != nil {
+		err = fmt.Errorf("reconfigure GPIO lines failed: %w", err)
+		return
+	}
+	if opts.consumer != "" {
+		err = fmt.Errorf("reconfigure GPIO lines failed: consumer can't be reconfigured")
+		return
+	}
+	configs, err := opts.lineConfigs(l.offsets)
+	if err != nil {
+		err = fmt.Errorf("reconfigure GPIO lines failed: %w", err)
+		return
+	}
+	if l.v2 {
+		var arg sys.GPIOV2LineConfig
+		if arg, err = opts.configV2(configs); err != nil {
+			err = fmt.Errorf("reconfigure GPIO lines failed: %w", err)
+			return
+		}
+		err = l.reconfigure(sys.GPIO_V2_LINE_SET_CONFIG_IOCTL, unsafe.Pointer(&arg), configs)
+	} else {
+		var edges EventFlag
+		var values []byte
+		if flags, edges, values, err = opts.requestV1(configs); err == nil && edges != 0 {
+			err = fmt.Errorf("%w: reconfiguring edges requires GPIO uAPI v2(Linux 5.10+)", ErrUnsupported)
+		}
+		if err != nil {
+			err = fmt.Errorf("reconfigure GPIO lines failed: %w", err)
+			return
+		}
+		var arg = sys.GPIOHandleConfig{Flags: uint32(flags)}
+		copy(arg.DefaultValues[:], values)
+		err = l.reconfigure(sys.GPIOHANDLE_SET_CONFIG_IOCTL, unsafe.Pointer(&arg), configs)
+	}
+	if err != nil {
+		err = fmt.Errorf("reconfigure GPIO lines failed: %w", err)
+		return
+	}
+	return
+}
+
+// reconfigure calls the ioctl of request to change the configurations of
+// the lines to configs, and updates the ones returned by Config on success.
+func (l *Lines) reconfigure(request uintptr, arg unsafe.Pointer, configs []lineConfig) (err error) {
+	// Hold the write lock, so the configurations are updated in the same order
+	// as the kernel ones.
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.fd < 0 {
+		return ErrClosed
+	}
+	if err = ioctl(l.sim, l.fd, request, arg); err != nil {
+		return
+	}
+	l.configs = lineConfigsOf(l.offsets, configs)
+	return
+}
@@ -0,0 +1,65 @@
+package gpio
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/mkch/asserting"
+	"golang.org/x/sys/unix"
+)
+
+func TestLinesMask(t1 *testing.T) {
+	t := NewTB(t1)
+	var lines = Lines{fd: -1, numLines: 3, v2: true}
+	t.AssertNoError(lines.checkMask(0b101))
+	t.AssertNoError(lines.checkMask(0b111))
+	t.Assert(lines.checkMask(0), NotEquals(nil))
+	t.Assert(lines.checkMask(0b1000), NotEquals(nil))
+
+	_, err := lines.ValuesMasked(0b1001)
+	t.Assert(err, NotEquals(nil))
+	t.Assert(lines.SetValuesMasked(0, 0), NotEquals(nil))
+
+	var linesV1 = Lines{fd: -1, numLines: 3}
+	t.Assert(linesV1.SetValuesMasked(0b1, 0b1), NotEquals(nil))
+}
+
+func TestValuesIntoShort(t1 *testing.T) {
+	t := NewTB(t1)
+	var lines = Lines{fd: -1, numLines: 3, v2: true}
+	t.Assert(lines.ValuesInto(make([]byte, 2)), NotEquals(nil))
+}
+
+func TestLinesConfig(t1 *testing.T) {
+	t := NewTB(t1)
+	var pipe [2]int
+	t.AssertNoError(unix.Pipe(pipe[:]))
+	defer unix.Close(pipe[1])
+
+	// Not a GPIO line, so Reconfigure fails with ENOTTY.
+	var lines = &Lines{fd: pipe[0], numLines: 2, offsets: []uint32{3, 5}}
+	defer lines.Close()
+	opts, err := newRequestOptions(Input, nil, "", 0, []LineOption{
+		ForOffset(5, WithOutput(1), WithActiveLow(true)),
+		ForOffset(3, WithDebounce(time.Millisecond))})
+	t.AssertNoError(err)
+	configs, err := opts.lineConfigs(lines.offsets)
+	t.AssertNoError(err)
+	lines.setConfig(configs)
+
+	var expected = []LineConfig{
+		{Offset: 3, Flags: Input, Debounce: time.Millisecond},
+		{Offset: 5, Flags: Output | ActiveLow},
+	}
+	t.AssertEqualSlice(lines.Config(), expected)
+	t.AssertEqual((*Line)(lines).Config(), expected[0])
+	lines.Config()[0].Flags = Output
+	t.AssertEqualSlice(lines.Config(), expected)
+	lines.Offsets()[0] = 4
+	t.AssertEqualSlice(lines.Offsets(), []uint32{3, 5})
+
+	// Failed reconfiguration does not change the config.
+	t.AssertTrue(errors.Is(lines.Reconfigure(Output, nil), unix.ENOTTY))
+	t.AssertEqualSlice(lines.Config(), expected)
+}
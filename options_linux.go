@@ -0,0 +1,446 @@
+package gpio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"time"
+
+	"github.com/mkch/gpio/internal/sys"
+)
+
+// LineOption is an optional configuration of requested lines.
+type LineOption func(opts *lineOptions)
+
+// lineOptions is the optional configuration set by LineOptions.
+type lineOptions struct {
+	debounce        time.Duration
+	eventBufferSize int
+	overflowPolicy  OverflowPolicy
+	eventClock      EventClock
+	// The flags, output values, consumer and edges of the request,
+	// initialized with the parameters of Chip.OpenLines and the like.
+	flags    LineFlag
+	values   []byte
+	consumer string
+	edges    EventFlag
+	// Options of certain offsets set by ForOffset.
+	overrides []lineOverride
+	// The error of an invalid option argument.
+	err error
+}
+
+// lineOverride is the options of a certain offset.
+type lineOverride struct {
+	offset  uint32
+	options []LineOption
+}
+
+// WithInput requests the lines as input. It is the default of Chip.RequestLines.
+func WithInput() LineOption {
+	return func(opts *lineOptions) {
+		opts.flags = opts.flags&^Output | Input
+	}
+}
+
+// WithOutput requests the lines as output, and sets the output values.
+// Value i is the value of the ith line in the requested offsets, 0 (low) or 1 (high),
+// anything else than 0 will be interpreted as 1 (high). The missing values are 0s.
+// In ForOffset, at most one value can be given for the line.
+func WithOutput(values ...byte) LineOption {
+	return func(opts *lineOptions) {
+		opts.flags = opts.flags&^Input | Output
+		opts.values = values
+	}
+}
+
+// WithActiveLow sets whether the lines are active low, which inverts the values
+// for reading and writing.
+func WithActiveLow(activeLow bool) LineOption {
+	return func(opts *lineOptions) {
+		if activeLow {
+			opts.flags |= ActiveLow
+		} else {
+			opts.flags &^= ActiveLow
+		}
+	}
+}
+
+// WithBias sets the bias of the lines, one of PullUp, PullDown and BiasDisabled,
+// or 0 to leave the bias as is. Requires Linux 5.5+.
+func WithBias(bias LineFlag) LineOption {
+	return func(opts *lineOptions) {
+		if bias != 0 && bias != PullUp && bias != PullDown && bias != BiasDisabled {
+			opts.setErr(fmt.Errorf("invalid bias %#x", uint32(bias)))
+			return
+		}
+		opts.flags = opts.flags&^(PullUp|PullDown|BiasDisabled) | bias
+	}
+}
+
+// WithDrive sets the drive of output lines, OpenDrain, OpenSource,
+// or 0 for push-pull, the default.
+func WithDrive(drive LineFlag) LineOption {
+	return func(opts *lineOptions) {
+		if drive != 0 && drive != OpenDrain && drive != OpenSource {
+			opts.setErr(fmt.Errorf("invalid drive %#x", uint32(drive)))
+			return
+		}
+		opts.flags = opts.flags&^(OpenDrain|OpenSource) | drive
+	}
+}
+
+// WithConsumer sets the consumer label of the lines, such as "my-bitbanged-relay".
+func WithConsumer(consumer string) LineOption {
+	return func(opts *lineOptions) {
+		opts.consumer = consumer
+	}
+}
+
+// WithEdges sets the edges of input lines to generate events on, 0 for none.
+// It only applies to Chip.RequestLinesWithEvents and the like, and the default
+// is BothEdges there.
+func WithEdges(edges EventFlag) LineOption {
+	return func(opts *lineOptions) {
+		opts.edges = edges
+	}
+}
+
+// ForOffset applies options to the line at offset only, overriding the options
+// of the request. Only WithInput, WithOutput, WithActiveLow, WithBias, WithDrive,
+// WithEdges and WithDebounce can be used in ForOffset.
+// Configuring the lines of a request differently requires uAPI v2(Linux 5.10+).
+func ForOffset(offset uint32, options ...LineOption) LineOption {
+	return func(opts *lineOptions) {
+		opts.overrides = append(opts.overrides, lineOverride{offset, options})
+	}
+}
+
+// WithDebounce sets the debounce period of input lines, including lines opened with
+// Chip.OpenLineWithEvents. Edges shorter than period are filtered out by the kernel.
+// Period is rounded up to microseconds, and 0 disables debouncing.
+// Requires uAPI v2(Linux 5.10+).
+func WithDebounce(period time.Duration) LineOption {
+	return func(opts *lineOptions) {
+		opts.debounce = period
+	}
+}
+
+// WithEventBuffer sets the buffer size of the event channel of lines opened with
+// Chip.OpenLineWithEvents, and what to do with a new event when the channel is full.
+// The default is a buffer of 1 with OverflowDropOldest, which only keeps the latest event.
+func WithEventBuffer(size int, policy OverflowPolicy) LineOption {
+	return func(opts *lineOptions) {
+		opts.eventBufferSize = size
+		opts.overflowPolicy = policy
+	}
+}
+
+// WithEventClock sets the clock used to timestamp the events of lines opened with
+// Chip.OpenLineWithEvents and Chip.OpenLinesWithEvents. The default is EventClockMonotonic.
+// Other clocks require uAPI v2(Linux 5.10+). Without uAPI v2, the kernel decides the
+// clock and it is detected automatically.
+func WithEventClock(clock EventClock) LineOption {
+	return func(opts *lineOptions) {
+		opts.eventClock = clock
+	}
+}
+
+// newLineOptions applies options and validates the result.
+func newLineOptions(options []LineOption) (opts lineOptions, err error) {
+	return newRequestOptions(0, nil, "", 0, options)
+}
+
+// newRequestOptions applies options to the request of flags, output values,
+// consumer and edges, and validates the result.
+func newRequestOptions(flags LineFlag, values []byte, consumer string, edges EventFlag, options []LineOption) (opts lineOptions, err error) {
+	opts.eventBufferSize = 1
+	opts.overflowPolicy = OverflowDropOldest
+	opts.flags = flags
+	opts.values = values
+	opts.consumer = consumer
+	opts.edges = edges
+	for _, option := range options {
+		option(&opts)
+	}
+	err = opts.validate()
+	return
+}
+
+// setErr records err of an invalid option argument, if there is none yet.
+func (opts *lineOptions) setErr(err error) {
+	if opts.err == nil {
+		opts.err = err
+	}
+}
+
+func (opts *lineOptions) validate() error {
+	if opts.err != nil {
+		return opts.err
+	}
+	if opts.edges&^BothEdges != 0 {
+		return fmt.Errorf("invalid edges %#x", uint32(opts.edges))
+	}
+	if opts.debounce < 0 || opts.debounce > math.MaxUint32*time.Microsecond {
+		return fmt.Errorf("invalid debounce period %v", opts.debounce)
+	}
+	if opts.eventBufferSize < 1 {
+		return fmt.Errorf("invalid event buffer size %v", opts.eventBufferSize)
+	}
+	if opts.overflowPolicy < OverflowDropOldest || opts.overflowPolicy > OverflowError {
+		return fmt.Errorf("invalid overflow policy %v", opts.overflowPolicy)
+	}
+	if opts.eventClock < EventClockMonotonic || opts.eventClock > EventClockHTE {
+		return fmt.Errorf("invalid event clock %v", opts.eventClock)
+	}
+	return nil
+}
+
+// checkV1 returns an error if any option is not available in uAPI v1.
+func (opts *lineOptions) checkV1() error {
+	if opts.debounce != 0 {
+		return fmt.Errorf("%w: debounce requires GPIO uAPI v2(Linux 5.10+)", ErrUnsupported)
+	}
+	if opts.eventClock != EventClockMonotonic {
+		return fmt.Errorf("%w: event clock %v requires GPIO uAPI v2(Linux 5.10+)", ErrUnsupported, opts.eventClock)
+	}
+	return nil
+}
+
+// applyV2 adds the options to the uAPI v2 line config of numLines lines.
+func (opts *lineOptions) applyV2(config *sys.GPIOV2LineConfig, numLines int) {
+	config.Flags |= opts.eventClockFlagsV2()
+	if opts.debounce != 0 {
+		attr := &config.Attrs[config.NumAttrs]
+		attr.Attr.ID = sys.GPIO_V2_LINE_ATTR_ID_DEBOUNCE
+		attr.Attr.SetDebouncePeriodUs(uint32(debounceMicroseconds(opts.debounce)))
+		attr.Mask = lineMask(numLines)
+		config.NumAttrs++
+	}
+}
+
+// debounceMicroseconds rounds d up to microseconds.
+func debounceMicroseconds(d time.Duration) int64 {
+	return int64((d + time.Microsecond - 1) / time.Microsecond)
+}
+
+// eventClockFlagsV2 returns the uAPI v2 line flags of the event clock.
+func (opts *lineOptions) eventClockFlagsV2() uint64 {
+	switch opts.eventClock {
+	case EventClockRealtime:
+		return sys.GPIO_V2_LINE_FLAG_EVENT_CLOCK_REALTIME
+	case EventClockHTE:
+		return sys.GPIO_V2_LINE_FLAG_EVENT_CLOCK_HTE
+	default:
+		return 0
+	}
+}
+
+// lineConfig is the configuration of a single requested line.
+type lineConfig struct {
+	flags    LineFlag
+	edges    EventFlag
+	debounce time.Duration
+	value    byte // The output value.
+}
+
+// validate returns an error if the combination of the configuration is
+// rejected by the kernel.
+func (config *lineConfig) validate() error {
+	const bias = PullUp | PullDown | BiasDisabled
+	const drive = OpenDrain | OpenSource
+	flags := config.flags
+	switch {
+	case flags&Input != 0 && flags&Output != 0:
+		return errors.New("input and output are mutually exclusive")
+	case flags&drive == drive:
+		return errors.New("open drain and open source are mutually exclusive")
+	case flags&drive != 0 && flags&Output == 0:
+		return errors.New("drive requires output")
+	case bits.OnesCount32(uint32(flags&bias)) > 1:
+		return errors.New("pull-up, pull-down and bias-disabled are mutually exclusive")
+	case flags&bias != 0 && flags&(Input|Output) == 0:
+		return errors.New("bias requires input or output")
+	case config.edges != 0 && flags&Output != 0:
+		return errors.New("edge detection requires input")
+	}
+	return nil
+}
+
+// lineConfigs returns the configurations of the lines at offsets, with the
+// options of ForOffset applied, and validates them.
+func (opts *lineOptions) lineConfigs(offsets []uint32) (configs []lineConfig, err error) {
+	configs = make([]lineConfig, len(offsets))
+	for i := range configs {
+		configs[i] = lineConfig{flags: opts.flags, edges: opts.edges, debounce: opts.debounce}
+		if i < len(opts.values) && opts.values[i] != 0 {
+			configs[i].value = 1
+		}
+	}
+	for _, override := range opts.overrides {
+		var i = indexOfOffset(offsets, override.offset)
+		if i < 0 {
+			return nil, fmt.Errorf("invalid options for offset %v: offset not requested", override.offset)
+		}
+		if configs[i], err = opts.override(configs[i], override.options); err != nil {
+			return nil, fmt.Errorf("invalid options for offset %v: %w", override.offset, err)
+		}
+	}
+	for i := range configs {
+		// Edge detection implies input.
+		if configs[i].edges != 0 && configs[i].flags&(Input|Output) == 0 {
+			configs[i].flags |= Input
+		}
+		if err = configs[i].validate(); err != nil {
+			if len(opts.overrides) > 0 {
+				err = fmt.Errorf("invalid options for offset %v: %w", offsets[i], err)
+			}
+			return nil, err
+		}
+	}
+	return
+}
+
+// override applies the options of ForOffset to config.
+func (opts *lineOptions) override(config lineConfig, options []LineOption) (lineConfig, error) {
+	var o = *opts
+	o.flags, o.edges, o.debounce = config.flags, config.edges, config.debounce
+	o.values = nil
+	o.overrides = nil
+	for _, option := range options {
+		option(&o)
+	}
+	if err := o.validate(); err != nil {
+		return config, err
+	}
+	if o.consumer != opts.consumer || o.eventBufferSize != opts.eventBufferSize ||
+		o.overflowPolicy != opts.overflowPolicy || o.eventClock != opts.eventClock || o.overrides != nil {
+		return config, errors.New("only direction, active-low, bias, drive, edges and debounce can be set for an offset")
+	}
+	if len(o.values) > 1 {
+		return config, fmt.Errorf("%v output values for a single line", len(o.values))
+	}
+	config.flags, config.edges, config.debounce = o.flags, o.edges, o.debounce
+	if len(o.values) == 1 {
+		config.value = 0
+		if o.values[0] != 0 {
+			config.value = 1
+		}
+	}
+	return config, nil
+}
+
+// indexOfOffset returns the index of offset in offsets, or -1 if not found.
+func indexOfOffset(offsets []uint32, offset uint32) int {
+	for i, o := range offsets {
+		if o == offset {
+			return i
+		}
+	}
+	return -1
+}
+
+// requestV1 returns the uAPI v1 request flags, event flags and output values
+// of the lines configured by configs.
+// It returns an error if the lines are configured differently or debounced,
+// which requires uAPI v2.
+func (opts *lineOptions) requestV1(configs []lineConfig) (flags LineFlag, edges EventFlag, values []byte, err error) {
+	if err = opts.checkV1(); err != nil {
+		return
+	}
+	values = make([]byte, len(configs))
+	for i := range configs {
+		config := &configs[i]
+		if config.debounce != 0 {
+			err = fmt.Errorf("%w: debounce requires GPIO uAPI v2(Linux 5.10+)", ErrUnsupported)
+			return
+		}
+		if config.flags != configs[0].flags || config.edges != configs[0].edges {
+			err = fmt.Errorf("%w: configuring lines differently requires GPIO uAPI v2(Linux 5.10+)", ErrUnsupported)
+			return
+		}
+		values[i] = config.value
+	}
+	if len(configs) > 0 {
+		flags, edges = configs[0].flags, configs[0].edges
+	}
+	return
+}
+
+// configV2 builds the uAPI v2 line config of the lines configured by configs.
+func (opts *lineOptions) configV2(configs []lineConfig) (config sys.GPIOV2LineConfig, err error) {
+	var n = len(configs)
+	if n == 0 {
+		return
+	}
+	var values = make([]byte, n)
+	var output bool
+	for i := range configs {
+		values[i] = configs[i].value
+		output = output || configs[i].flags&Output != 0
+	}
+	config = lineConfigV2(n, values, uint32(configs[0].flags), uint32(configs[0].edges))
+	baseFlags := config.Flags
+	if output && baseFlags&sys.GPIO_V2_LINE_FLAG_OUTPUT == 0 {
+		if err = addAttrV2(&config, sys.GPIO_V2_LINE_ATTR_ID_OUTPUT_VALUES, valuesToBits(values), lineMask(n)); err != nil {
+			return
+		}
+	}
+	// The kernel uses the first attribute containing a line, so the attributes
+	// of certain lines are added before the ones of all lines added by applyV2.
+	for i := range configs {
+		var mask = uint64(1) << uint(i)
+		if flags := requestFlagsV2(uint32(configs[i].flags), uint32(configs[i].edges)); flags != baseFlags {
+			if err = addAttrV2(&config, sys.GPIO_V2_LINE_ATTR_ID_FLAGS, flags|opts.eventClockFlagsV2(), mask); err != nil {
+				return
+			}
+		}
+		if configs[i].debounce != opts.debounce {
+			if err = addAttrV2(&config, sys.GPIO_V2_LINE_ATTR_ID_DEBOUNCE, uint64(debounceMicroseconds(configs[i].debounce)), mask); err != nil {
+				return
+			}
+		}
+	}
+	if opts.debounce != 0 && config.NumAttrs == sys.GPIO_V2_LINE_NUM_ATTRS_MAX {
+		err = errTooManyAttrs
+		return
+	}
+	opts.applyV2(&config, n)
+	return
+}
+
+var errTooManyAttrs = fmt.Errorf("too many different line configurations, at most %v", sys.GPIO_V2_LINE_NUM_ATTRS_MAX)
+
+// addAttrV2 adds the lines in mask to the attribute of config with id and value.
+// The attribute is added to config if not found.
+func addAttrV2(config *sys.GPIOV2LineConfig, id uint32, value uint64, mask uint64) error {
+	for i := uint32(0); i < config.NumAttrs; i++ {
+		attr := &config.Attrs[i]
+		if attr.Attr.ID == id && attrValueV2(&attr.Attr) == value {
+			attr.Mask |= mask
+			return nil
+		}
+	}
+	if config.NumAttrs == sys.GPIO_V2_LINE_NUM_ATTRS_MAX {
+		return errTooManyAttrs
+	}
+	attr := &config.Attrs[config.NumAttrs]
+	attr.Attr.ID = id
+	if id == sys.GPIO_V2_LINE_ATTR_ID_DEBOUNCE {
+		attr.Attr.SetDebouncePeriodUs(uint32(value))
+	} else {
+		attr.Attr.SetFlags(value)
+	}
+	attr.Mask = mask
+	config.NumAttrs++
+	return nil
+}
+
+// attrValueV2 returns the flags, values or debounce period of attr.
+func attrValueV2(attr *sys.GPIOV2LineAttribute) uint64 {
+	if attr.ID == sys.GPIO_V2_LINE_ATTR_ID_DEBOUNCE {
+		return uint64(attr.DebouncePeriodUs())
+	}
+	return attr.Flags()
+}
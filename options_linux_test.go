@@ -0,0 +1,218 @@
+package gpio
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/mkch/asserting"
+	"github.com/mkch/gpio/internal/sys"
+)
+
+func TestDebounceOption(t1 *testing.T) {
+	t := NewTB(t1)
+
+	opts, err := newLineOptions([]LineOption{WithDebounce(1500 * time.Nanosecond)})
+	t.AssertNoError(err)
+	t.Assert(opts.checkV1(), NotEquals(nil))
+
+	config := lineConfigV2(2, nil, sys.GPIOHANDLE_REQUEST_INPUT, 0)
+	opts.applyV2(&config, 2)
+	t.AssertEqual(config.NumAttrs, uint32(1))
+	t.AssertEqual(config.Attrs[0].Attr.ID, uint32(sys.GPIO_V2_LINE_ATTR_ID_DEBOUNCE))
+	t.AssertEqual(config.Attrs[0].Attr.DebouncePeriodUs(), uint32(2))
+	t.AssertEqual(config.Attrs[0].Mask, uint64(0b11))
+
+	_, err = newLineOptions([]LineOption{WithDebounce(-time.Millisecond)})
+	t.Assert(err, NotEquals(nil))
+	_, err = newLineOptions([]LineOption{WithDebounce(2 * time.Hour)})
+	t.Assert(err, NotEquals(nil))
+
+	opts, err = newLineOptions(nil)
+	t.AssertNoError(err)
+	t.AssertNoError(opts.checkV1())
+}
+
+func TestEventBufferOption(t1 *testing.T) {
+	t := NewTB(t1)
+
+	opts, err := newLineOptions(nil)
+	t.AssertNoError(err)
+	t.AssertEqual(opts.eventBufferSize, 1)
+	t.AssertEqual(opts.overflowPolicy, OverflowDropOldest)
+
+	opts, err = newLineOptions([]LineOption{WithEventBuffer(64, OverflowBlock)})
+	t.AssertNoError(err)
+	t.AssertEqual(opts.eventBufferSize, 64)
+	t.AssertEqual(opts.overflowPolicy, OverflowBlock)
+	t.AssertNoError(opts.checkV1())
+
+	_, err = newLineOptions([]LineOption{WithEventBuffer(0, OverflowBlock)})
+	t.Assert(err, NotEquals(nil))
+	_, err = newLineOptions([]LineOption{WithEventBuffer(1, OverflowPolicy(-1))})
+	t.Assert(err, NotEquals(nil))
+}
+
+func TestEventClockOption(t1 *testing.T) {
+	t := NewTB(t1)
+
+	opts, err := newLineOptions(nil)
+	t.AssertNoError(err)
+	t.AssertEqual(opts.eventClock, EventClockMonotonic)
+
+	opts, err = newLineOptions([]LineOption{WithEventClock(EventClockRealtime)})
+	t.AssertNoError(err)
+	t.Assert(opts.checkV1(), NotEquals(nil))
+	config := lineConfigV2(1, nil, sys.GPIOHANDLE_REQUEST_INPUT, sys.GPIOEVENT_REQUEST_RISING_EDGE)
+	opts.applyV2(&config, 1)
+	t.AssertEqual(config.Flags, uint64(sys.GPIO_V2_LINE_FLAG_INPUT|sys.GPIO_V2_LINE_FLAG_EDGE_RISING|sys.GPIO_V2_LINE_FLAG_EVENT_CLOCK_REALTIME))
+
+	opts, err = newLineOptions([]LineOption{WithEventClock(EventClockHTE)})
+	t.AssertNoError(err)
+	config = lineConfigV2(1, nil, sys.GPIOHANDLE_REQUEST_INPUT, sys.GPIOEVENT_REQUEST_RISING_EDGE)
+	opts.applyV2(&config, 1)
+	t.AssertEqual(config.Flags&sys.GPIO_V2_LINE_FLAG_EVENT_CLOCK_HTE, uint64(sys.GPIO_V2_LINE_FLAG_EVENT_CLOCK_HTE))
+
+	_, err = newLineOptions([]LineOption{WithEventClock(EventClock(3))})
+	t.Assert(err, NotEquals(nil))
+}
+
+func TestRequestOptions(t1 *testing.T) {
+	t := NewTB(t1)
+
+	opts, err := newRequestOptions(Input, nil, "", 0, []LineOption{
+		WithOutput(1, 0), WithActiveLow(true), WithBias(PullUp), WithDrive(OpenDrain), WithConsumer("a")})
+	t.AssertNoError(err)
+	t.AssertEqual(opts.flags, Output|ActiveLow|PullUp|OpenDrain)
+	t.AssertEqualSlice(opts.values, []byte{1, 0})
+	t.AssertEqual(opts.consumer, "a")
+
+	opts, err = newRequestOptions(Output|ActiveLow|PullUp|OpenDrain, nil, "", 0, []LineOption{
+		WithInput(), WithActiveLow(false), WithBias(0), WithDrive(0)})
+	t.AssertNoError(err)
+	t.AssertEqual(opts.flags, Input)
+
+	_, err = newRequestOptions(Input, nil, "", 0, []LineOption{WithBias(Output)})
+	t.Assert(err, NotEquals(nil))
+	_, err = newRequestOptions(Input, nil, "", 0, []LineOption{WithDrive(PullUp)})
+	t.Assert(err, NotEquals(nil))
+	_, err = newRequestOptions(Input, nil, "", 0, []LineOption{WithEdges(BothEdges << 1)})
+	t.Assert(err, NotEquals(nil))
+}
+
+func TestLineConfigs(t1 *testing.T) {
+	t := NewTB(t1)
+	var offsets = []uint32{3, 5, 7}
+
+	opts, err := newRequestOptions(Input, nil, "", 0, []LineOption{
+		WithOutput(1, 0, 2),
+		ForOffset(5, WithInput(), WithBias(PullDown)),
+		ForOffset(7, WithActiveLow(true)),
+		ForOffset(7, WithOutput(0)),
+	})
+	t.AssertNoError(err)
+	configs, err := opts.lineConfigs(offsets)
+	t.AssertNoError(err)
+	t.AssertEqualSlice(configs, []lineConfig{
+		{flags: Output, value: 1},
+		{flags: Input | PullDown},
+		{flags: Output | ActiveLow, value: 0},
+	})
+
+	// Edge detection implies input.
+	opts, err = newRequestOptions(0, nil, "", RisingEdge, []LineOption{WithBias(PullUp)})
+	t.AssertNoError(err)
+	configs, err = opts.lineConfigs(offsets[:1])
+	t.AssertNoError(err)
+	t.AssertEqual(configs[0].flags, Input|PullUp)
+
+	for _, options := range [][]LineOption{
+		{WithDrive(OpenSource)},
+		{WithOutput(), WithEdges(BothEdges)},
+		{WithBias(PullUp), func(opts *lineOptions) { opts.flags |= PullDown }},
+		{ForOffset(4, WithInput())},
+		{ForOffset(5, WithConsumer("a"))},
+		{ForOffset(5, WithEventClock(EventClockRealtime))},
+		{ForOffset(5, WithOutput(1, 1))},
+		{ForOffset(5, ForOffset(5))},
+		{ForOffset(5, WithOutput(), WithEdges(BothEdges))},
+	} {
+		opts, err = newRequestOptions(Input, nil, "", 0, options)
+		if err == nil {
+			_, err = opts.lineConfigs(offsets)
+		}
+		t.Assert(err, NotEquals(nil))
+	}
+}
+
+func TestRequestV1(t1 *testing.T) {
+	t := NewTB(t1)
+	opts, err := newRequestOptions(Output, []byte{1, 0, 1}, "", 0, nil)
+	t.AssertNoError(err)
+	configs, err := opts.lineConfigs([]uint32{1, 2, 3})
+	t.AssertNoError(err)
+	flags, edges, values, err := opts.requestV1(configs)
+	t.AssertNoError(err)
+	t.AssertEqual(flags, Output)
+	t.AssertEqual(edges, EventFlag(0))
+	t.AssertEqualSlice(values, []byte{1, 0, 1})
+
+	for _, options := range [][]LineOption{
+		{ForOffset(2, WithActiveLow(true))},
+		{ForOffset(2, WithDebounce(time.Millisecond))},
+	} {
+		opts, err = newRequestOptions(Input, nil, "", 0, options)
+		t.AssertNoError(err)
+		configs, err = opts.lineConfigs([]uint32{1, 2, 3})
+		t.AssertNoError(err)
+		_, _, _, err = opts.requestV1(configs)
+		t.AssertTrue(errors.Is(err, ErrUnsupported))
+	}
+}
+
+func TestConfigV2(t1 *testing.T) {
+	t := NewTB(t1)
+	opts, err := newRequestOptions(Input, nil, "", 0, []LineOption{
+		WithDebounce(time.Millisecond),
+		WithEventClock(EventClockRealtime),
+		ForOffset(5, WithOutput(1)),
+		ForOffset(7, WithOutput(1), WithDebounce(0)),
+		ForOffset(9, WithDebounce(0)),
+	})
+	t.AssertNoError(err)
+	configs, err := opts.lineConfigs([]uint32{3, 5, 7, 9})
+	t.AssertNoError(err)
+	config, err := opts.configV2(configs)
+	t.AssertNoError(err)
+
+	t.AssertEqual(config.Flags, uint64(sys.GPIO_V2_LINE_FLAG_INPUT|sys.GPIO_V2_LINE_FLAG_EVENT_CLOCK_REALTIME))
+	t.AssertEqual(config.NumAttrs, uint32(4))
+	attrs := config.Attrs[:config.NumAttrs]
+	t.AssertEqual(attrs[0].Attr.ID, uint32(sys.GPIO_V2_LINE_ATTR_ID_OUTPUT_VALUES))
+	t.AssertEqual(attrs[0].Attr.Values(), uint64(0b0110))
+	t.AssertEqual(attrs[0].Mask, uint64(0b1111))
+	t.AssertEqual(attrs[1].Attr.ID, uint32(sys.GPIO_V2_LINE_ATTR_ID_FLAGS))
+	t.AssertEqual(attrs[1].Attr.Flags(), uint64(sys.GPIO_V2_LINE_FLAG_OUTPUT|sys.GPIO_V2_LINE_FLAG_EVENT_CLOCK_REALTIME))
+	t.AssertEqual(attrs[1].Mask, uint64(0b0110))
+	// The debounce periods of certain lines go before the one of all lines.
+	t.AssertEqual(attrs[2].Attr.ID, uint32(sys.GPIO_V2_LINE_ATTR_ID_DEBOUNCE))
+	t.AssertEqual(attrs[2].Attr.DebouncePeriodUs(), uint32(0))
+	t.AssertEqual(attrs[2].Mask, uint64(0b1100))
+	t.AssertEqual(attrs[3].Attr.ID, uint32(sys.GPIO_V2_LINE_ATTR_ID_DEBOUNCE))
+	t.AssertEqual(attrs[3].Attr.DebouncePeriodUs(), uint32(1000))
+	t.AssertEqual(attrs[3].Mask, uint64(0b1111))
+
+	// Too many attributes.
+	var offsets []uint32
+	var options []LineOption
+	for i := uint32(0); i <= sys.GPIO_V2_LINE_NUM_ATTRS_MAX; i++ {
+		offsets = append(offsets, i)
+		options = append(options, ForOffset(i, WithDebounce(time.Duration(i+1)*time.Microsecond)))
+	}
+	opts, err = newRequestOptions(Input, nil, "", 0, options)
+	t.AssertNoError(err)
+	configs, err = opts.lineConfigs(offsets)
+	t.AssertNoError(err)
+	_, err = opts.configV2(configs)
+	t.Assert(err, NotEquals(nil))
+}
@@ -0,0 +1,139 @@
+// Capture the edges of GPIO lines as a VCD file, which can be viewed in
+// waveform viewers such as GTKWave and PulseView.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mkch/gpio"
+	"github.com/mkch/gpio/vcd"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), `Usage: gpio-capture [options]...
+Capture edges on GPIO lines to a VCD file, until interrupted or a limit is reached`)
+		flag.PrintDefaults()
+		fmt.Fprintln(flag.CommandLine.Output(), `
+Example:
+gpio-capture -n gpiochip0 -o 4 -o 5 -t 10s -w capture.vcd`)
+	}
+	deviceName := flag.String("n", "", "Capture GPIOs on a `name`d device (must be stated)")
+	var offsets offsetFlag
+	flag.Var(&offsets, "o", "The `offset`[s] to capture, at least one, several can be stated")
+	duration := flag.Duration("t", 0, "Capture for a `duration`, such as 10s (optional, unlimited if not stated)")
+	events := flag.Int("c", 0, "Capture <`n`> edges (optional, unlimited if not stated)")
+	debounce := flag.Duration("p", 0, "Set the debounce `period`, such as 5ms")
+	buffer := flag.Int("b", 1024, "Buffer <`n`> events not yet written")
+	output := flag.String("w", "", "Write to `file` (optional, standard output if not stated)")
+	flag.Parse()
+
+	if len(*deviceName) == 0 || len(offsets) == 0 {
+		flag.Usage()
+		os.Exit(-1)
+	}
+
+	if *buffer <= 0 {
+		fmt.Fprintln(os.Stderr, "The event buffer must be at least 1")
+		os.Exit(-1)
+	}
+
+	err := capture(*deviceName, offsets, *duration, *events, *debounce, *buffer, *output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		switch {
+		case errors.Is(err, gpio.ErrPermission):
+			fmt.Fprintln(os.Stderr, "Check the access rights of the GPIO chip device, usually granted by udev rules.")
+		case errors.Is(err, gpio.ErrLineBusy):
+			fmt.Fprintln(os.Stderr, "Release the line from its current consumer first.")
+		case errors.Is(err, vcd.ErrDropped):
+			fmt.Fprintln(os.Stderr, "The capture is incomplete. Increase the event buffer with -b.")
+		}
+		var errno syscall.Errno
+		if errors.As(err, &errno) {
+			os.Exit(-int(errno))
+		}
+		os.Exit(-1)
+	}
+}
+
+func capture(deviceName string, offsets []uint32, duration time.Duration, events int, debounce time.Duration, buffer int, output string) (err error) {
+	chip, err := gpio.OpenChip(deviceName)
+	if err != nil {
+		return
+	}
+	defer chip.Close()
+
+	// Dropped edges are not visible in the waveform, so buffer the bursts.
+	var options = []gpio.LineOption{gpio.WithEventBuffer(buffer, gpio.OverflowDropOldest)}
+	if debounce != 0 {
+		options = append(options, gpio.WithDebounce(debounce))
+	}
+	lines, err := chip.OpenLinesWithEvents(offsets, gpio.Input, gpio.BothEdges, "gpio-capture", options...)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	var w = os.Stdout
+	if output != "" {
+		if w, err = os.Create(output); err != nil {
+			return
+		}
+		defer func() {
+			if err1 := w.Close(); err == nil {
+				err = err1
+			}
+		}()
+	}
+
+	// Stop capturing on Ctrl-C, with a complete file.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	fmt.Fprintf(os.Stderr, "Capturing line(s) %v on %v\n", offsets, deviceName)
+	r := vcd.NewRecorder(vcd.WithDuration(duration), vcd.WithMaxEvents(events))
+	r.AddLines(nil, lines)
+	if err = r.Record(ctx, w); errors.Is(err, context.Canceled) {
+		err = nil
+	}
+	return
+}
+
+type offsetFlag []uint32
+
+func (f offsetFlag) String() string {
+	var s = make([]string, len(f))
+	for i, v := range f {
+		s[i] = strconv.Itoa(int(v))
+	}
+	return strings.Join(s, ",")
+}
+
+func (f *offsetFlag) Set(str string) (err error) {
+	v, err := strconv.ParseUint(str, 10, 32)
+	if err != nil {
+		return
+	}
+	*f = append(*f, uint32(v))
+	return
+}
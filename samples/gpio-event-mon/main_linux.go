@@ -7,7 +7,10 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/mkch/gpio"
 )
@@ -19,14 +22,16 @@ Listen to events on GPIO lines, 0->1 1->0`)
 		flag.PrintDefaults()
 		fmt.Fprintln(flag.CommandLine.Output(), `
 Example:
-gpio-event-mon -n gpiochip0 -o 4 -r -f`)
+gpio-event-mon -n gpiochip0 -o 4 -o 5 -r -f`)
 	}
 	deviceName := flag.String("n", "", "Listen on GPIOs on a `name`d device (must be stated)")
-	line := flag.Int("o", 0, "The `offset` to monitor")
+	var offsets offsetFlag
+	flag.Var(&offsets, "o", "The `offset`[s] to monitor, at least one, several can be stated")
 	openDrain := flag.Bool("d", false, "Set line as open drain")
 	openSource := flag.Bool("s", false, "Set line as open source")
 	risingEdge := flag.Bool("r", false, "Listen for rising edges")
 	fallingEdge := flag.Bool("f", false, "Listen for rising edges")
+	debounce := flag.Duration("p", 0, "Set the debounce `period`, such as 5ms")
 	loops := flag.Uint("c", 0, "Do <`n`> loops (optional, infinite loop if not stated)")
 	flag.Parse()
 
@@ -46,7 +51,7 @@ gpio-event-mon -n gpiochip0 -o 4 -r -f`)
 		eventFlags |= gpio.FallingEdge
 	}
 
-	if len(*deviceName) == 0 || *line == -1 {
+	if len(*deviceName) == 0 || len(offsets) == 0 {
 		flag.Usage()
 		os.Exit(-1)
 	}
@@ -56,9 +61,15 @@ gpio-event-mon -n gpiochip0 -o 4 -r -f`)
 		eventFlags = gpio.BothEdges
 	}
 
-	err := monitorDevice(*deviceName, *line, handleFlags, eventFlags, *loops)
+	err := monitorDevice(*deviceName, offsets, handleFlags, eventFlags, *debounce, *loops)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		switch {
+		case errors.Is(err, gpio.ErrPermission):
+			fmt.Fprintln(os.Stderr, "Check the access rights of the GPIO chip device, usually granted by udev rules.")
+		case errors.Is(err, gpio.ErrLineBusy):
+			fmt.Fprintln(os.Stderr, "Release the line from its current consumer first.")
+		}
 		var errno syscall.Errno
 		if errors.As(err, &errno) {
 			os.Exit(-int(errno))
@@ -67,35 +78,35 @@ gpio-event-mon -n gpiochip0 -o 4 -r -f`)
 	}
 }
 
-func monitorDevice(deviceName string, lineOffset int, handleFlags gpio.LineFlag, eventFlags gpio.EventFlag, loops uint) (err error) {
+func monitorDevice(deviceName string, offsets []uint32, handleFlags gpio.LineFlag, eventFlags gpio.EventFlag, debounce time.Duration, loops uint) (err error) {
 	chip, err := gpio.OpenChip(deviceName)
 	if err != nil {
 		return
 	}
 	defer chip.Close()
 
-	line, err := chip.OpenLineWithEvents(uint32(lineOffset), handleFlags, eventFlags, "gpio-event-mon")
+	var options []gpio.LineOption
+	if debounce != 0 {
+		options = append(options, gpio.WithDebounce(debounce))
+	}
+	lines, err := chip.OpenLinesWithEvents(offsets, handleFlags, eventFlags, "gpio-event-mon", options...)
 	if err != nil {
 		return
 	}
-	defer line.Close()
+	defer lines.Close()
 
 	// Read initial states
-	value, err := line.Value()
+	values, err := lines.Values()
 	if err != nil {
 		return
 	}
 
-	fmt.Printf("Monitoring line %v on %v\n", lineOffset, deviceName)
-	fmt.Printf("Initial line value: %v\n", value)
+	fmt.Printf("Monitoring line(s) %v on %v\n", offsetFlag(offsets), deviceName)
+	fmt.Printf("Initial line value(s): %v\n", values)
 
 	var i uint
-	for event := range line.Events() {
-		fmt.Printf("GPIO EVENT %v: ", event.Time)
-		value, err = line.Value()
-		if err != nil {
-			return
-		}
+	for event := range lines.Events() {
+		fmt.Printf("GPIO EVENT at %v on line %v (%v|%v) ", event.Time, event.Offset, event.LineSeqno, event.Seqno)
 		if event.RisingEdge {
 			fmt.Println("rising edge")
 		} else {
@@ -103,8 +114,29 @@ func monitorDevice(deviceName string, lineOffset int, handleFlags gpio.LineFlag,
 		}
 		i++
 		if i == loops {
-			break
+			return
 		}
 	}
+	// The event channel is closed because of an error.
+	err = lines.Err()
+	return
+}
+
+type offsetFlag []uint32
+
+func (f offsetFlag) String() string {
+	var s = make([]string, len(f))
+	for i, v := range f {
+		s[i] = strconv.Itoa(int(v))
+	}
+	return strings.Join(s, ",")
+}
+
+func (f *offsetFlag) Set(str string) (err error) {
+	v, err := strconv.ParseUint(str, 10, 32)
+	if err != nil {
+		return
+	}
+	*f = append(*f, uint32(v))
 	return
 }
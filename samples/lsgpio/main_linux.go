@@ -91,6 +91,21 @@ func listDevice(deviceName string) (err error) {
 		if lineInfo.OpenSource() {
 			flags = append(flags, "open-source")
 		}
+		if lineInfo.PullUp() {
+			flags = append(flags, "pull-up")
+		}
+		if lineInfo.PullDown() {
+			flags = append(flags, "pull-down")
+		}
+		if lineInfo.BiasDisabled() {
+			flags = append(flags, "bias-disabled")
+		}
+		if clock := lineInfo.EventClock(); clock != gpio.EventClockMonotonic {
+			flags = append(flags, "clock-"+clock.String())
+		}
+		if lineInfo.DebouncePeriod != 0 {
+			flags = append(flags, fmt.Sprintf("debounce_period=%vusec", lineInfo.DebouncePeriod.Microseconds()))
+		}
 		fmt.Printf(" [%v]\n", strings.Join(flags, " "))
 	}
 	return
@@ -0,0 +1,40 @@
+package sysfsadapter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mkch/gpio"
+	"github.com/mkch/gpio/gpiosysfs"
+)
+
+var (
+	_ gpio.Reader          = (*gpiosysfs.Pin)(nil)
+	_ gpio.Writer          = (*gpiosysfs.Pin)(nil)
+	_ gpio.DirectionSetter = (*gpiosysfs.Pin)(nil)
+	_ gpio.Reader          = (*gpiosysfs.PinWithEvent)(nil)
+)
+
+// EdgeWatcher adapts pin to gpio.EdgeWatcher.
+func EdgeWatcher(pin *gpiosysfs.PinWithEvent) gpio.EdgeWatcher {
+	return edgeWatcher{pin}
+}
+
+type edgeWatcher struct {
+	*gpiosysfs.PinWithEvent
+}
+
+func (w edgeWatcher) WaitEdge(ctx context.Context, edge gpio.EventFlag) (*gpio.Event, error) {
+	var sysfsEdge gpiosysfs.Edge
+	switch edge {
+	case gpio.RisingEdge:
+		sysfsEdge = gpiosysfs.Rising
+	case gpio.FallingEdge:
+		sysfsEdge = gpiosysfs.Falling
+	case gpio.BothEdges:
+		sysfsEdge = gpiosysfs.Both
+	default:
+		return nil, fmt.Errorf("wait GPIO edge failed: invalid edge %v", edge)
+	}
+	return w.PinWithEvent.WaitEdge(ctx, sysfsEdge)
+}
@@ -0,0 +1,14 @@
+/*
+Package sysfsadapter adapts the pins of package github.com/mkch/gpio/gpiosysfs
+to the interfaces of package github.com/mkch/gpio, such as gpio.EdgeWatcher,
+so drivers written against the interfaces work with the legacy sysfs interface.
+
+*gpiosysfs.Pin implements gpio.Reader, gpio.Writer and gpio.DirectionSetter as is.
+*gpiosysfs.PinWithEvent implements gpio.Reader, and is adapted to gpio.EdgeWatcher
+by EdgeWatcher.
+
+	pin, err := gpiosysfs.OpenPinWithEvents(17)
+	...
+	event, err := sysfsadapter.EdgeWatcher(pin).WaitEdge(ctx, gpio.RisingEdge)
+*/
+package sysfsadapter
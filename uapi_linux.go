@@ -0,0 +1,119 @@
+package gpio
+
+import (
+	"github.com/mkch/gpio/internal/sys"
+)
+
+// requestFlagsV2 converts uAPI v1 request flags and event flags to uAPI v2 line flags.
+func requestFlagsV2(requestFlags, eventFlags uint32) (flags uint64) {
+	if requestFlags&sys.GPIOHANDLE_REQUEST_INPUT != 0 {
+		flags |= sys.GPIO_V2_LINE_FLAG_INPUT
+	}
+	if requestFlags&sys.GPIOHANDLE_REQUEST_OUTPUT != 0 {
+		flags |= sys.GPIO_V2_LINE_FLAG_OUTPUT
+	}
+	if requestFlags&sys.GPIOHANDLE_REQUEST_ACTIVE_LOW != 0 {
+		flags |= sys.GPIO_V2_LINE_FLAG_ACTIVE_LOW
+	}
+	if requestFlags&sys.GPIOHANDLE_REQUEST_OPEN_DRAIN != 0 {
+		flags |= sys.GPIO_V2_LINE_FLAG_OPEN_DRAIN
+	}
+	if requestFlags&sys.GPIOHANDLE_REQUEST_OPEN_SOURCE != 0 {
+		flags |= sys.GPIO_V2_LINE_FLAG_OPEN_SOURCE
+	}
+	if requestFlags&sys.GPIOHANDLE_REQUEST_BIAS_PULL_UP != 0 {
+		flags |= sys.GPIO_V2_LINE_FLAG_BIAS_PULL_UP
+	}
+	if requestFlags&sys.GPIOHANDLE_REQUEST_BIAS_PULL_DOWN != 0 {
+		flags |= sys.GPIO_V2_LINE_FLAG_BIAS_PULL_DOWN
+	}
+	if requestFlags&sys.GPIOHANDLE_REQUEST_BIAS_DISABLE != 0 {
+		flags |= sys.GPIO_V2_LINE_FLAG_BIAS_DISABLED
+	}
+	if eventFlags&sys.GPIOEVENT_REQUEST_RISING_EDGE != 0 {
+		flags |= sys.GPIO_V2_LINE_FLAG_EDGE_RISING
+	}
+	if eventFlags&sys.GPIOEVENT_REQUEST_FALLING_EDGE != 0 {
+		flags |= sys.GPIO_V2_LINE_FLAG_EDGE_FALLING
+	}
+	// Edge detection is only available on input lines in uAPI v2.
+	// It is implied in uAPI v1.
+	if eventFlags != 0 {
+		flags |= sys.GPIO_V2_LINE_FLAG_INPUT
+	}
+	return
+}
+
+// lineInfoFlagsV2 converts uAPI v1 line info flags to uAPI v2 line flags.
+func lineInfoFlagsV2(infoFlags uint32) (flags uint64) {
+	if infoFlags&sys.GPIOLINE_FLAG_KERNEL != 0 {
+		flags |= sys.GPIO_V2_LINE_FLAG_USED
+	}
+	if infoFlags&sys.GPIOLINE_FLAG_IS_OUT != 0 {
+		flags |= sys.GPIO_V2_LINE_FLAG_OUTPUT
+	} else {
+		flags |= sys.GPIO_V2_LINE_FLAG_INPUT
+	}
+	if infoFlags&sys.GPIOLINE_FLAG_ACTIVE_LOW != 0 {
+		flags |= sys.GPIO_V2_LINE_FLAG_ACTIVE_LOW
+	}
+	if infoFlags&sys.GPIOLINE_FLAG_OPEN_DRAIN != 0 {
+		flags |= sys.GPIO_V2_LINE_FLAG_OPEN_DRAIN
+	}
+	if infoFlags&sys.GPIOLINE_FLAG_OPEN_SOURCE != 0 {
+		flags |= sys.GPIO_V2_LINE_FLAG_OPEN_SOURCE
+	}
+	if infoFlags&sys.GPIOLINE_FLAG_BIAS_PULL_UP != 0 {
+		flags |= sys.GPIO_V2_LINE_FLAG_BIAS_PULL_UP
+	}
+	if infoFlags&sys.GPIOLINE_FLAG_BIAS_PULL_DOWN != 0 {
+		flags |= sys.GPIO_V2_LINE_FLAG_BIAS_PULL_DOWN
+	}
+	if infoFlags&sys.GPIOLINE_FLAG_BIAS_DISABLE != 0 {
+		flags |= sys.GPIO_V2_LINE_FLAG_BIAS_DISABLED
+	}
+	return
+}
+
+// lineConfigV2 builds the uAPI v2 line config of numLines lines
+// from uAPI v1 request flags, event flags and default output values.
+func lineConfigV2(numLines int, outputDefaultValues []byte, requestFlags, eventFlags uint32) (config sys.GPIOV2LineConfig) {
+	config.Flags = requestFlagsV2(requestFlags, eventFlags)
+	if config.Flags&sys.GPIO_V2_LINE_FLAG_OUTPUT != 0 {
+		attr := &config.Attrs[config.NumAttrs]
+		attr.Attr.ID = sys.GPIO_V2_LINE_ATTR_ID_OUTPUT_VALUES
+		attr.Attr.SetValues(valuesToBits(outputDefaultValues))
+		attr.Mask = lineMask(numLines)
+		config.NumAttrs++
+	}
+	return
+}
+
+// lineMask returns the bitmap of the first n lines of a request.
+func lineMask(n int) uint64 {
+	if n >= 64 {
+		return ^uint64(0)
+	}
+	return uint64(1)<<uint(n) - 1
+}
+
+// valuesToBits converts 0/1 values to a bitmap, bit i is set if values[i] is not 0.
+// Values after the 64th are ignored.
+func valuesToBits(values []byte) (bits uint64) {
+	if len(values) > 64 {
+		values = values[:64]
+	}
+	for i, v := range values {
+		if v != 0 {
+			bits |= 1 << uint(i)
+		}
+	}
+	return
+}
+
+// bitsToValues stores bit i of bits to values[i] as 0 or 1.
+func bitsToValues(bits uint64, values []byte) {
+	for i := range values {
+		values[i] = byte(bits >> uint(i) & 1)
+	}
+}
@@ -0,0 +1,54 @@
+package gpio
+
+import (
+	"testing"
+
+	. "github.com/mkch/asserting"
+	"github.com/mkch/gpio/internal/sys"
+)
+
+func TestLineMask(t1 *testing.T) {
+	t := NewTB(t1)
+	t.AssertEqual(lineMask(0), uint64(0))
+	t.AssertEqual(lineMask(1), uint64(1))
+	t.AssertEqual(lineMask(3), uint64(0b111))
+	t.AssertEqual(lineMask(64), ^uint64(0))
+}
+
+func TestValuesBits(t1 *testing.T) {
+	t := NewTB(t1)
+	t.AssertEqual(valuesToBits([]byte{1, 0, 2, 0}), uint64(0b101))
+	t.AssertEqual(valuesToBits(nil), uint64(0))
+
+	var values = make([]byte, 4)
+	bitsToValues(0b1101, values)
+	t.AssertEqualSlice(values, []byte{1, 0, 1, 1})
+}
+
+func TestLineConfigV2(t1 *testing.T) {
+	t := NewTB(t1)
+
+	config := lineConfigV2(3, []byte{1, 0, 1}, sys.GPIOHANDLE_REQUEST_OUTPUT|sys.GPIOHANDLE_REQUEST_ACTIVE_LOW, 0)
+	t.AssertEqual(config.Flags, uint64(sys.GPIO_V2_LINE_FLAG_OUTPUT|sys.GPIO_V2_LINE_FLAG_ACTIVE_LOW))
+	t.AssertEqual(config.NumAttrs, uint32(1))
+	t.AssertEqual(config.Attrs[0].Attr.ID, uint32(sys.GPIO_V2_LINE_ATTR_ID_OUTPUT_VALUES))
+	t.AssertEqual(config.Attrs[0].Attr.Values(), uint64(0b101))
+	t.AssertEqual(config.Attrs[0].Mask, uint64(0b111))
+
+	config = lineConfigV2(2, nil, sys.GPIOHANDLE_REQUEST_INPUT|sys.GPIOHANDLE_REQUEST_BIAS_PULL_UP, 0)
+	t.AssertEqual(config.Flags, uint64(sys.GPIO_V2_LINE_FLAG_INPUT|sys.GPIO_V2_LINE_FLAG_BIAS_PULL_UP))
+	t.AssertEqual(config.NumAttrs, uint32(0))
+
+	config = lineConfigV2(1, []byte{1}, sys.GPIOHANDLE_REQUEST_INPUT, sys.GPIOEVENT_REQUEST_BOTH_EDGES)
+	t.AssertEqual(config.Flags, uint64(sys.GPIO_V2_LINE_FLAG_INPUT|sys.GPIO_V2_LINE_FLAG_EDGE_RISING|sys.GPIO_V2_LINE_FLAG_EDGE_FALLING))
+	t.AssertEqual(config.NumAttrs, uint32(0))
+}
+
+func TestLineInfoFlagsV2(t1 *testing.T) {
+	t := NewTB(t1)
+	t.AssertEqual(lineInfoFlagsV2(0), uint64(sys.GPIO_V2_LINE_FLAG_INPUT))
+	t.AssertEqual(lineInfoFlagsV2(sys.GPIOLINE_FLAG_KERNEL|sys.GPIOLINE_FLAG_IS_OUT|sys.GPIOLINE_FLAG_OPEN_DRAIN),
+		uint64(sys.GPIO_V2_LINE_FLAG_USED|sys.GPIO_V2_LINE_FLAG_OUTPUT|sys.GPIO_V2_LINE_FLAG_OPEN_DRAIN))
+	t.AssertEqual(lineInfoFlagsV2(sys.GPIOLINE_FLAG_BIAS_PULL_DOWN),
+		uint64(sys.GPIO_V2_LINE_FLAG_INPUT|sys.GPIO_V2_LINE_FLAG_BIAS_PULL_DOWN))
+}
@@ -0,0 +1,10 @@
+/*
+Package vcd records the edges of GPIO lines as VCD (Value Change Dump) files,
+which can be viewed in waveform viewers such as GTKWave and PulseView.
+
+The edges are written with the timestamps of the kernel, in nanoseconds since the
+recording starts.
+
+Ref: IEEE 1364-2005, section 18 "Value change dump (VCD) files"
+*/
+package vcd
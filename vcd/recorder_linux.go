@@ -0,0 +1,284 @@
+package vcd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/mkch/gpio"
+	"golang.org/x/sys/unix"
+)
+
+// Recorder records the edges of GPIO lines as VCD files.
+// The lines must be requested for both edges, and timestamped with the same
+// event clock, see WithEventClock.
+//
+// The event channels of the lines must be buffered by gpio.WithEventBuffer
+// for the bursts of edges. The default buffer keeps only the latest event,
+// and a missing edge is not visible in the waveform, so the events discarded
+// because a channel is full are reported by Record.
+type Recorder struct {
+	sources   []*source
+	signals   []*signal
+	clock     gpio.EventClock
+	duration  time.Duration
+	maxEvents int
+}
+
+// source is the events of a line request.
+type source struct {
+	events      <-chan *gpio.Event
+	err         func() error
+	values      func() ([]byte, error)
+	overwritten func() uint64
+	offsets     []uint32
+	signals     []*signal // Indexed like offsets.
+	dropped     uint64    // The value of overwritten last seen.
+}
+
+// ErrDropped is the error returned by Record if some events are discarded
+// because the event channels are full. Test it with errors.Is.
+var ErrDropped = errors.New("events dropped")
+
+// signal is a recorded line.
+type signal struct {
+	name  string
+	id    string
+	value byte // The last written value.
+}
+
+// Option is an optional configuration of Recorder.
+type Option func(r *Recorder)
+
+// WithDuration sets how long Record records. No limit by default.
+func WithDuration(duration time.Duration) Option {
+	return func(r *Recorder) {
+		r.duration = duration
+	}
+}
+
+// WithMaxEvents sets the number of edges Record records. No limit by default.
+func WithMaxEvents(n int) Option {
+	return func(r *Recorder) {
+		r.maxEvents = n
+	}
+}
+
+// WithEventClock sets the event clock of the recorded lines, which must be the
+// one set by gpio.WithEventClock when requesting the lines.
+// The default is gpio.EventClockMonotonic, and gpio.EventClockHTE is not supported.
+func WithEventClock(clock gpio.EventClock) Option {
+	return func(r *Recorder) {
+		r.clock = clock
+	}
+}
+
+// NewRecorder returns a Recorder configured by options such as WithDuration.
+func NewRecorder(options ...Option) *Recorder {
+	var r = &Recorder{clock: gpio.EventClockMonotonic}
+	for _, option := range options {
+		option(r)
+	}
+	return r
+}
+
+// AddLine adds a line to be recorded as a signal named name.
+// If name is empty, the signal is named after the offset of the line, such as "line4".
+func (r *Recorder) AddLine(name string, line *gpio.LineWithEvent) {
+	r.add(&source{
+		events: line.Events(),
+		err:    line.Err,
+		values: func() ([]byte, error) {
+			value, err := line.Value()
+			return []byte{value}, err
+		},
+		overwritten: line.Overwritten,
+		offsets:     []uint32{line.Config().Offset},
+	}, []string{name})
+}
+
+// AddLines adds lines to be recorded as signals named names, which are in the
+// order of lines.Offsets(). Empty or missing names are named after the offsets,
+// see AddLine.
+func (r *Recorder) AddLines(names []string, lines *gpio.LinesWithEvents) {
+	r.add(&source{
+		events:      lines.Events(),
+		err:         lines.Err,
+		values:      lines.Values,
+		overwritten: lines.Overwritten,
+		offsets:     lines.Offsets(),
+	}, names)
+}
+
+// add adds src with the names of signals.
+func (r *Recorder) add(src *source, names []string) {
+	for i, offset := range src.offsets {
+		var s = &signal{id: identifier(len(r.signals))}
+		if i < len(names) {
+			s.name = names[i]
+		}
+		if s.name == "" {
+			s.name = fmt.Sprintf("line%d", offset)
+		}
+		src.signals = append(src.signals, s)
+		r.signals = append(r.signals, s)
+	}
+	r.sources = append(r.sources, src)
+}
+
+// Record reads the current values of the lines, and records the edges to w
+// until the duration or the number of edges set by the options is reached,
+// or all the event channels are closed. The data is flushed to w whenever
+// no event is pending, so w can be a pipe to a viewer.
+//
+// If an event channel is closed because of an error, Record returns the error.
+// Otherwise, if some events are discarded because an event channel is full,
+// Record returns an error wrapping ErrDropped, and the discarded events are noted
+// as comments at the time of the next events. Otherwise, if ctx is done first,
+// Record returns ctx.Err(). The recorded data is a complete VCD file in all cases.
+//
+// The edges occurred before Record are discarded, and the edges of different
+// line requests are written in the order they are received. Record can be
+// called again to record another file.
+func (r *Recorder) Record(ctx context.Context, w io.Writer) (err error) {
+	if len(r.sources) == 0 {
+		return errors.New("record GPIO lines failed: no line")
+	}
+	start, err := clockNow(r.clock)
+	if err != nil {
+		return fmt.Errorf("record GPIO lines failed: %w", err)
+	}
+	var startTime = time.Now()
+	for _, src := range r.sources {
+		values, err := src.values()
+		if err != nil {
+			return fmt.Errorf("record GPIO lines failed: %w", err)
+		}
+		for i, s := range src.signals {
+			s.value = values[i]
+		}
+		src.dropped = src.overwritten()
+	}
+	vw := newWriter(w)
+	vw.header(startTime, r.signals)
+	vw.dumpVars(r.signals)
+
+	// The select cases: the event channels, ctx.Done(), the duration and default.
+	var cases = make([]reflect.SelectCase, len(r.sources), len(r.sources)+3)
+	for i, src := range r.sources {
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(src.events)}
+	}
+	var doneCase, durationCase = len(cases), len(cases) + 1
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+	var durationChan <-chan time.Time
+	if r.duration > 0 {
+		timer := time.NewTimer(r.duration)
+		defer timer.Stop()
+		durationChan = timer.C
+	}
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(durationChan)})
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+
+	var dropped uint64 // The number of events discarded.
+	// end writes the end time and flushes, and returns the error of stopping:
+	// the error of writing, err, the dropped events or stopErr in that order.
+	end := func(t time.Duration, err, stopErr error) error {
+		vw.timestamp(t)
+		if err1 := vw.flush(); err1 != nil {
+			return fmt.Errorf("record GPIO lines failed: %w", err1)
+		}
+		if err != nil {
+			return fmt.Errorf("record GPIO lines failed: %w", err)
+		}
+		if dropped > 0 {
+			return fmt.Errorf("record GPIO lines failed: %v %w", dropped, ErrDropped)
+		}
+		return stopErr
+	}
+	var open, count = len(r.sources), 0
+	for open > 0 {
+		chosen, recv, ok := reflect.Select(cases)
+		if chosen == len(cases)-1 {
+			// Nothing is pending.
+			if err = vw.flush(); err != nil {
+				return fmt.Errorf("record GPIO lines failed: %w", err)
+			}
+			chosen, recv, ok = reflect.Select(cases[:len(cases)-1])
+		}
+		switch {
+		case chosen == doneCase:
+			return end(time.Since(startTime), nil, ctx.Err())
+		case chosen == durationCase:
+			return end(r.duration, nil, nil)
+		case !ok:
+			// The event channel is closed.
+			cases[chosen].Chan = reflect.Value{}
+			open--
+			if err = r.sources[chosen].err(); err != nil {
+				return end(time.Since(startTime), err, nil)
+			}
+			continue
+		}
+		event := recv.Interface().(*gpio.Event)
+		t := event.Timestamp - start
+		if t < 0 {
+			continue
+		}
+		if r.duration > 0 && t > r.duration {
+			return end(r.duration, nil, nil)
+		}
+		src := r.sources[chosen]
+		// Note the events discarded since the last event of src.
+		if n := src.overwritten(); n != src.dropped {
+			vw.comment(t, fmt.Sprintf("%v events of %v dropped", n-src.dropped, src.names()))
+			dropped += n - src.dropped
+			src.dropped = n
+		}
+		for i, offset := range src.offsets {
+			if offset == event.Offset {
+				var value byte
+				if event.RisingEdge {
+					value = 1
+				}
+				vw.change(t, src.signals[i], value)
+				break
+			}
+		}
+		count++
+		if r.maxEvents > 0 && count >= r.maxEvents {
+			return end(t, nil, nil)
+		}
+	}
+	return end(time.Since(startTime), nil, nil)
+}
+
+// names returns the names of the signals of src separated by commas.
+func (src *source) names() string {
+	var names = make([]string, len(src.signals))
+	for i, s := range src.signals {
+		names[i] = reference(s.name)
+	}
+	return strings.Join(names, ",")
+}
+
+// clockNow returns the current time of the event clock.
+func clockNow(clock gpio.EventClock) (time.Duration, error) {
+	var id int32
+	switch clock {
+	case gpio.EventClockMonotonic:
+		id = unix.CLOCK_MONOTONIC
+	case gpio.EventClockRealtime:
+		id = unix.CLOCK_REALTIME
+	default:
+		return 0, fmt.Errorf("%v is not supported", clock)
+	}
+	var ts unix.Timespec
+	if err := unix.ClockGettime(id, &ts); err != nil {
+		return 0, fmt.Errorf("failed to call clock_gettime: %w", err)
+	}
+	return time.Duration(ts.Nano()), nil
+}
@@ -0,0 +1,215 @@
+package vcd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/mkch/asserting"
+	"github.com/mkch/gpio"
+	"github.com/mkch/gpio/gpiotest"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+// record starts r.Record in a goroutine, and waits for the initial values
+// to be written. The returned channel receives the result of Record.
+func record(t TB, ctx context.Context, r *Recorder, w *syncBuffer) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		result <- r.Record(ctx, w)
+	}()
+	waitFor(t, w, "$dumpvars")
+	return result
+}
+
+// waitFor waits until w contains s.
+func waitFor(t TB, w *syncBuffer, s string) {
+	for start := time.Now(); !strings.Contains(w.String(), s); time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatalf("%q not written", s)
+		}
+	}
+}
+
+// changes returns the value changes after the initial values in a VCD file.
+func changes(vcd string) []string {
+	return strings.Fields(vcd[strings.Index(vcd, "$dumpvars"):])
+}
+
+func TestRecord(t1 *testing.T) {
+	t := NewTB(t1)
+	sim, err := gpiotest.NewChip("vcd-record", "", 4)
+	t.AssertNoError(err)
+	defer sim.Close()
+	chip, err := sim.Open()
+	t.AssertNoError(err)
+	defer chip.Close()
+	t.AssertNoError(sim.Drive(0, 1))
+	lines, err := chip.RequestLinesWithEvents([]uint32{0, 1})
+	t.AssertNoError(err)
+	defer lines.Close()
+	line, err := chip.RequestLineWithEvents(3)
+	t.AssertNoError(err)
+	defer line.Close()
+
+	r := NewRecorder(WithMaxEvents(4))
+	r.AddLines([]string{"SDA"}, lines)
+	r.AddLine("CLK", line)
+	var buf syncBuffer
+	result := record(t, context.Background(), r, &buf)
+
+	start, err := clockNow(gpio.EventClockMonotonic)
+	t.AssertNoError(err)
+	start += time.Second // Surely after the start of recording.
+	t.AssertNoError(sim.DriveAt(3, 1, start))
+	// The events of different requests are not ordered.
+	waitFor(t, &buf, "\n1#\n")
+	t.AssertNoError(sim.DriveAt(0, 0, start+500*time.Nanosecond))
+	t.AssertNoError(sim.DriveAt(1, 1, start+500*time.Nanosecond))
+	waitFor(t, &buf, "\n1\"\n")
+	t.AssertNoError(sim.DriveAt(3, 0, start+time.Microsecond))
+	select {
+	case err := <-result:
+		t.AssertNoError(err)
+	case <-time.After(time.Second):
+		t1.Fatal("recording not stopped")
+	}
+
+	vcd := buf.String()
+	t.AssertTrue(strings.Contains(vcd, "$var wire 1 ! SDA $end\n$var wire 1 \" line1 $end\n$var wire 1 # CLK $end\n"))
+	c := changes(vcd)
+	t.AssertEqualSlice(c[:5], []string{"$dumpvars", "1!", "0\"", "0#", "$end"})
+	t.AssertEqualSlice([]string{c[6], c[8], c[9], c[11]}, []string{"1#", "0!", "1\"", "0#"})
+	t.AssertEqual(len(c), 12) // Stopped at the last edge.
+	var times [3]time.Duration
+	for i, s := range []string{c[5], c[7], c[10]} {
+		d, err := time.ParseDuration(s[1:] + "ns")
+		t.AssertNoError(err)
+		times[i] = d
+	}
+	t.AssertEqual(times[1]-times[0], 500*time.Nanosecond)
+	t.AssertEqual(times[2]-times[0], time.Microsecond)
+}
+
+func TestRecordDuration(t1 *testing.T) {
+	t := NewTB(t1)
+	sim, err := gpiotest.NewChip("vcd-duration", "", 1)
+	t.AssertNoError(err)
+	defer sim.Close()
+	chip, err := sim.Open()
+	t.AssertNoError(err)
+	defer chip.Close()
+	line, err := chip.RequestLineWithEvents(0)
+	t.AssertNoError(err)
+	defer line.Close()
+
+	r := NewRecorder(WithDuration(20 * time.Millisecond))
+	r.AddLine("", line)
+	var buf bytes.Buffer
+	t.AssertNoError(r.Record(context.Background(), &buf))
+	t.AssertTrue(strings.HasSuffix(buf.String(), "$end\n#20000000\n"))
+	t.AssertTrue(strings.Contains(buf.String(), " line0 $end"))
+
+	// Canceled.
+	ctx, cancel := context.WithCancel(context.Background())
+	r = NewRecorder()
+	r.AddLine("", line)
+	var syncBuf syncBuffer
+	result := record(t, ctx, r, &syncBuf)
+	t.AssertNoError(sim.Drive(0, 1))
+	waitFor(t, &syncBuf, "\n1!\n")
+	cancel()
+	t.AssertTrue(errors.Is(<-result, context.Canceled))
+
+	// Closed.
+	r = NewRecorder()
+	r.AddLine("", line)
+	result = record(t, context.Background(), r, &syncBuffer{})
+	t.AssertNoError(line.Close())
+	t.AssertNoError(<-result)
+}
+
+// gatedWriter is a syncBuffer whose writing can be blocked.
+type gatedWriter struct {
+	syncBuffer
+	gate sync.Mutex // Locked to block writing.
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	w.gate.Lock()
+	defer w.gate.Unlock()
+	return w.syncBuffer.Write(p)
+}
+
+func TestRecordDropped(t1 *testing.T) {
+	t := NewTB(t1)
+	sim, err := gpiotest.NewChip("vcd-dropped", "", 1)
+	t.AssertNoError(err)
+	defer sim.Close()
+	chip, err := sim.Open()
+	t.AssertNoError(err)
+	defer chip.Close()
+	// The default buffer of one event.
+	line, err := chip.RequestLineWithEvents(0)
+	t.AssertNoError(err)
+	defer line.Close()
+
+	r := NewRecorder()
+	r.AddLine("", line)
+	var w gatedWriter
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result := make(chan error, 1)
+	go func() {
+		result <- r.Record(ctx, &w)
+	}()
+	waitFor(t, &w.syncBuffer, "$dumpvars")
+
+	// Edges faster than recorded.
+	w.gate.Lock()
+	for _, value := range []byte{1, 0, 1, 0} {
+		t.AssertNoError(sim.Drive(0, value))
+	}
+	for start := time.Now(); line.Overwritten() == 0; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatal("events not dropped")
+		}
+	}
+	w.gate.Unlock()
+	waitFor(t, &w.syncBuffer, " events of line0 dropped\n$end\n")
+	cancel()
+	err = <-result
+	t.AssertTrue(errors.Is(err, ErrDropped))
+	t.AssertTrue(!errors.Is(err, context.Canceled))
+	// The last edge is recorded after the comment.
+	vcd := w.String()
+	t.AssertTrue(strings.Contains(vcd[strings.Index(vcd, "dropped"):], "\n0!\n"))
+}
+
+func TestRecordError(t1 *testing.T) {
+	t := NewTB(t1)
+	t.Assert(NewRecorder().Record(context.Background(), &bytes.Buffer{}), NotEquals(nil))
+	_, err := clockNow(gpio.EventClockHTE)
+	t.Assert(err, NotEquals(nil))
+}
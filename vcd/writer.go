@@ -0,0 +1,97 @@
+package vcd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// writer writes 1-bit signals in VCD format with a time scale of 1ns.
+// The write errors are reported by flush.
+type writer struct {
+	w *bufio.Writer
+	// The last written time, -1 if no time is written.
+	time time.Duration
+}
+
+func newWriter(w io.Writer) *writer {
+	return &writer{w: bufio.NewWriter(w), time: -1}
+}
+
+// header writes the header and the definitions of signals.
+func (w *writer) header(date time.Time, signals []*signal) {
+	fmt.Fprintf(w.w, "$date\n\t%v\n$end\n", date.Format(time.RFC1123))
+	fmt.Fprintf(w.w, "$version\n\tgithub.com/mkch/gpio/vcd\n$end\n")
+	fmt.Fprintf(w.w, "$timescale 1ns $end\n")
+	fmt.Fprintf(w.w, "$scope module gpio $end\n")
+	for _, s := range signals {
+		fmt.Fprintf(w.w, "$var wire 1 %v %v $end\n", s.id, reference(s.name))
+	}
+	fmt.Fprintf(w.w, "$upscope $end\n$enddefinitions $end\n")
+}
+
+// dumpVars writes the initial values of signals at time 0.
+func (w *writer) dumpVars(signals []*signal) {
+	w.timestamp(0)
+	fmt.Fprintf(w.w, "$dumpvars\n")
+	for _, s := range signals {
+		fmt.Fprintf(w.w, "%v%v\n", s.value, s.id)
+	}
+	fmt.Fprintf(w.w, "$end\n")
+}
+
+// change writes the value change of signal s at time t.
+// A change earlier than the last written time is written at that time,
+// because the time of VCD can't go backwards.
+func (w *writer) change(t time.Duration, s *signal, value byte) {
+	if value == s.value {
+		return
+	}
+	s.value = value
+	w.timestamp(t)
+	fmt.Fprintf(w.w, "%v%v\n", value, s.id)
+}
+
+// comment writes a comment at time t.
+func (w *writer) comment(t time.Duration, text string) {
+	w.timestamp(t)
+	fmt.Fprintf(w.w, "$comment\n\t%v\n$end\n", text)
+}
+
+// timestamp writes time t if it is later than the last written time.
+func (w *writer) timestamp(t time.Duration) {
+	if t <= w.time {
+		return
+	}
+	w.time = t
+	fmt.Fprintf(w.w, "#%d\n", int64(t/time.Nanosecond))
+}
+
+// flush writes the buffered data to the underlying io.Writer, and returns
+// the error of writing, if any.
+func (w *writer) flush() error {
+	return w.w.Flush()
+}
+
+// identifier returns the identifier code of the ith signal, made of
+// printable ASCII characters.
+func identifier(i int) string {
+	const first, n = '!', '~' - '!' + 1
+	var id []byte
+	for {
+		id = append(id, byte(first+i%n))
+		i /= n
+		if i == 0 {
+			break
+		}
+		i--
+	}
+	return string(id)
+}
+
+// reference returns name as a VCD reference, which can't contain white spaces.
+func reference(name string) string {
+	return strings.Join(strings.Fields(name), "_")
+}
@@ -0,0 +1,60 @@
+package vcd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/mkch/asserting"
+)
+
+func TestIdentifier(t1 *testing.T) {
+	t := NewTB(t1)
+	t.AssertEqual(identifier(0), "!")
+	t.AssertEqual(identifier(93), "~")
+	t.AssertEqual(identifier(94), "!!")
+	t.AssertEqual(identifier(95), "\"!")
+	var ids = make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		ids[identifier(i)] = true
+	}
+	t.AssertEqual(len(ids), 10000)
+}
+
+func TestWriter(t1 *testing.T) {
+	t := NewTB(t1)
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	var signals = []*signal{{name: "SDA", id: "!", value: 1}, {name: "bit bang\tCLK", id: "\"", value: 0}}
+	w.header(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), signals)
+	w.dumpVars(signals)
+	w.change(1500*time.Nanosecond, signals[0], 0)
+	w.change(1500*time.Nanosecond, signals[1], 1)
+	w.change(2*time.Microsecond, signals[1], 1) // Not changed.
+	w.change(time.Microsecond, signals[1], 0)   // Out of order.
+	w.timestamp(3 * time.Microsecond)
+	t.AssertNoError(w.flush())
+	t.AssertEqual(buf.String(), `$date
+	Thu, 02 Jan 2020 03:04:05 UTC
+$end
+$version
+	github.com/mkch/gpio/vcd
+$end
+$timescale 1ns $end
+$scope module gpio $end
+$var wire 1 ! SDA $end
+$var wire 1 " bit_bang_CLK $end
+$upscope $end
+$enddefinitions $end
+#0
+$dumpvars
+1!
+0"
+$end
+#1500
+0!
+1"
+0"
+#3000
+`)
+}
@@ -0,0 +1,189 @@
+package gpio
+
+import (
+	"fmt"
+	"io"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/mkch/gpio/internal/fdevents"
+	"github.com/mkch/gpio/internal/sys"
+	"golang.org/x/sys/unix"
+)
+
+// LineInfoChangeType is the type of line info changes.
+type LineInfoChangeType uint32
+
+const (
+	// LineRequested means the line has been requested.
+	LineRequested = LineInfoChangeType(sys.GPIO_V2_LINE_CHANGED_REQUESTED)
+	// LineReleased means the line has been released.
+	LineReleased = LineInfoChangeType(sys.GPIO_V2_LINE_CHANGED_RELEASED)
+	// LineReconfigured means the line has been reconfigured.
+	LineReconfigured = LineInfoChangeType(sys.GPIO_V2_LINE_CHANGED_CONFIG)
+)
+
+func (t LineInfoChangeType) String() string {
+	switch t {
+	case LineRequested:
+		return "requested"
+	case LineReleased:
+		return "released"
+	case LineReconfigured:
+		return "reconfigured"
+	default:
+		return fmt.Sprintf("LineInfoChangeType(%d)", uint32(t))
+	}
+}
+
+// LineInfoChange is a change in the status of a watched GPIO line.
+type LineInfoChange struct {
+	Type LineInfoChangeType
+	Info LineInfo  // The updated line info.
+	Time time.Time // The best estimate of time of the change.
+}
+
+// The size of the buffered channel returned by Chip.LineInfoChanges.
+const lineInfoChangesBufferSize = 16
+
+// WatchLineInfo starts watching the status changes of a certain GPIO line,
+// and returns the current information about it. Requires Linux 5.7+.
+// The changes are sent to the channel returned by LineInfoChanges.
+// Offset is the local line offset on this GPIO chip.
+func (c *Chip) WatchLineInfo(offset uint32) (info LineInfo, err error) {
+	err = c.startInfoWatcher()
+	if err != nil {
+		err = c.lineError("watch GPIO line info", []uint32{offset}, err)
+		return
+	}
+	if c.v1 {
+		var arg = sys.GPIOLineInfo{LineOffset: offset}
+		err = c.ioctl(sys.GPIO_GET_LINEINFO_WATCH_IOCTL, unsafe.Pointer(&arg))
+		info = lineInfoV1(&arg)
+	} else {
+		var arg = sys.GPIOV2LineInfo{Offset: offset}
+		err = c.ioctl(sys.GPIO_V2_GET_LINEINFO_WATCH_IOCTL, unsafe.Pointer(&arg))
+		info = lineInfoV2(&arg)
+	}
+	if err != nil {
+		err = c.lineError("watch GPIO line info", []uint32{offset}, err)
+		return
+	}
+	return
+}
+
+// UnwatchLineInfo stops watching the status changes of a certain GPIO line.
+// Offset is the local line offset on this GPIO chip.
+func (c *Chip) UnwatchLineInfo(offset uint32) (err error) {
+	var arg = offset
+	err = c.ioctl(sys.GPIO_GET_LINEINFO_UNWATCH_IOCTL, unsafe.Pointer(&arg))
+	if err != nil {
+		err = c.lineError("unwatch GPIO line info", []uint32{offset}, err)
+		return
+	}
+	return
+}
+
+// LineInfoChanges returns a channel from which the status changes of the
+// lines watched by WatchLineInfo can be read. The channel is closed when c is closed.
+//
+// Package gpio will not block sending to the channel: if the buffer of the channel
+// is full, the oldest unread change is discarded.
+func (c *Chip) LineInfoChanges() <-chan *LineInfoChange {
+	return c.infoChanges
+}
+
+// LineInfoChangesErr returns the error that caused the channel returned by
+// LineInfoChanges to be closed. It returns nil if the channel is open or
+// closed by Close.
+func (c *Chip) LineInfoChangesErr() error {
+	c.infoErrLock.Lock()
+	defer c.infoErrLock.Unlock()
+	return c.infoErr
+}
+
+// startInfoWatcher starts the epoll_wait loop reading line info changes from
+// the chip fd, if not started yet.
+func (c *Chip) startInfoWatcher() (err error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.fd < 0 {
+		return ErrClosed
+	}
+	c.infoWatcherLock.Lock()
+	defer c.infoWatcherLock.Unlock()
+	if c.infoWatcher != nil {
+		return
+	}
+	var read = readLineInfoChangedV2
+	if c.v1 {
+		read = readLineInfoChangedV1
+	}
+	c.infoWatcher, err = fdevents.Watch(c.fd, false /*NOT close fd*/, unix.EPOLLIN, func(fd int) error {
+		change, err := read(fd)
+		if err != nil || change == nil {
+			return err
+		}
+		select {
+		case c.infoChanges <- change:
+		default:
+			// Discard the oldest.
+			select {
+			case <-c.infoChanges:
+			default:
+			}
+			c.infoChanges <- change
+		}
+		return nil
+	}, func(err error) {
+		if err != nil {
+			c.infoErrLock.Lock()
+			c.infoErr = err
+			c.infoErrLock.Unlock()
+		}
+		close(c.infoChanges)
+	})
+	return
+}
+
+func readLineInfoChangedV2(fd int) (*LineInfoChange, error) {
+	var data sys.GPIOV2LineInfoChanged
+	_, err := io.ReadFull(sys.FdReader(fd), (*[unsafe.Sizeof(data)]byte)(unsafe.Pointer(&data))[:])
+	if err != nil {
+		if err == syscall.EINTR {
+			return nil, nil // ignore
+		}
+		return nil, fmt.Errorf("failed to read GPIO line info change: %w", err)
+	}
+	t, err := monotonicTime(data.TimestampNs)
+	if err != nil {
+		return nil, err
+	}
+	return &LineInfoChange{
+		Type: LineInfoChangeType(data.EventType),
+		Info: lineInfoV2(&data.Info),
+		Time: t,
+	}, nil
+}
+
+func readLineInfoChangedV1(fd int) (*LineInfoChange, error) {
+	var data sys.GPIOLineInfoChanged
+	_, err := io.ReadFull(sys.FdReader(fd), (*[unsafe.Sizeof(data)]byte)(unsafe.Pointer(&data))[:])
+	if err != nil {
+		if err == syscall.EINTR {
+			return nil, nil // ignore
+		}
+		return nil, fmt.Errorf("failed to read GPIO line info change: %w", err)
+	}
+	// The timestamp of line info changes is always read from CLOCK_MONOTONIC.
+	t, err := monotonicTime(data.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	return &LineInfoChange{
+		Type: LineInfoChangeType(data.EventType),
+		Info: lineInfoV1(&data.Info),
+		Time: t,
+	}, nil
+}